@@ -0,0 +1,32 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+// Checks register themselves with the checker registry when their package is
+// initialized. Import new check packages here to make them available to the
+// commands.
+import (
+	_ "github.com/iomesh/debugtool/pkg/disk/benchmark"
+	_ "github.com/iomesh/debugtool/pkg/disk/inventory"
+	_ "github.com/iomesh/debugtool/pkg/infra/clock"
+	_ "github.com/iomesh/debugtool/pkg/infra/dns"
+	_ "github.com/iomesh/debugtool/pkg/infra/service"
+	_ "github.com/iomesh/debugtool/pkg/network/cni"
+	_ "github.com/iomesh/debugtool/pkg/network/hostnetwork"
+	_ "github.com/iomesh/debugtool/pkg/node/capacity"
+	_ "github.com/iomesh/debugtool/pkg/node/compatibility"
+	_ "github.com/iomesh/debugtool/pkg/node/iscsi"
+	_ "github.com/iomesh/debugtool/pkg/node/tunables"
+)
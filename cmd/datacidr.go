@@ -0,0 +1,114 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mattn/go-isatty"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+	"github.com/iomesh/debugtool/pkg/config"
+	"github.com/iomesh/debugtool/pkg/network/discovery"
+)
+
+// resolveDataCIDR discovers the data network when it was not given. A single
+// candidate is used as is, the user picks one of several candidates when
+// running in a terminal, otherwise the candidates are reported.
+func resolveDataCIDR(ctx context.Context) error {
+	cfg := config.Get()
+	if !cfg.DataCIDRUnset() {
+		return nil
+	}
+
+	candidates, err := discovery.NewDiscoverer().Discover(ctx)
+	if err != nil {
+		return fmt.Errorf("Discover data network: %v", err)
+	}
+	if cidr, ok := discovery.DualStack(candidates); ok {
+		candidates = []discovery.Candidate{{CIDR: cidr}}
+	}
+	switch {
+	case len(candidates) == 0:
+		return errors.New("No network shared by all nodes besides the Kubernetes node network, set the data network with --data-cidr or IOMESH_DATA_CIDR")
+	case len(candidates) == 1:
+		cfg.DataCIDR = candidates[0].CIDR
+		if checker.Interactive() {
+			fmt.Printf("Using discovered data network %s\n\n", cfg.DataCIDR)
+		}
+		return nil
+	case checker.Interactive() && isatty.IsTerminal(os.Stdin.Fd()):
+		cidr, err := promptCandidate(candidates)
+		if err != nil {
+			return err
+		}
+		cfg.DataCIDR = cidr
+		return nil
+	}
+
+	cidrs := []string{}
+	for _, candidate := range candidates {
+		cidrs = append(cidrs, candidate.CIDR)
+	}
+	return fmt.Errorf("Found several candidate data networks %s, choose one with --data-cidr or IOMESH_DATA_CIDR", strings.Join(cidrs, ", "))
+}
+
+func promptCandidate(candidates []discovery.Candidate) (string, error) {
+	fmt.Println("Found several candidate data networks:")
+	writeCandidates(os.Stdout, candidates)
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Printf("Data network to check [1-%d]: ", len(candidates))
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return "", fmt.Errorf("Read data network choice: %v", err)
+		}
+		i, err := strconv.Atoi(strings.TrimSpace(line))
+		if err == nil && i >= 1 && i <= len(candidates) {
+			fmt.Println("")
+			return candidates[i-1].CIDR, nil
+		}
+	}
+}
+
+// writeCandidates lists the candidate data networks with the interface and
+// address of each node in them.
+func writeCandidates(w io.Writer, candidates []discovery.Candidate) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for i, candidate := range candidates {
+		nodes := []string{}
+		for node := range candidate.Interfaces {
+			nodes = append(nodes, node)
+		}
+		sort.Strings(nodes)
+		for j, node := range nodes {
+			index, cidr := "", ""
+			if j == 0 {
+				index, cidr = fmt.Sprintf("%d)", i+1), candidate.CIDR
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\n", index, cidr, node, candidate.Interfaces[node], candidate.Addresses[node])
+		}
+	}
+	tw.Flush()
+}
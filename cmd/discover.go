@@ -0,0 +1,47 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/iomesh/debugtool/pkg/network/discovery"
+)
+
+// discoverCmd represents the discover command
+var discoverCmd = &cobra.Command{
+	Use:   "discover",
+	Short: "List the networks of the nodes that can be the IOMesh data network",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		candidates, err := discovery.NewDiscoverer().Discover(context.Background())
+		if err != nil {
+			return err
+		}
+		if len(candidates) == 0 {
+			fmt.Println("No network shared by all nodes besides the Kubernetes node network")
+			return nil
+		}
+		writeCandidates(os.Stdout, candidates)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(discoverCmd)
+}
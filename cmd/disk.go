@@ -12,14 +12,22 @@ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
 See the License for the specific language governing permissions and
 limitations under the License.
 */
-package network
+package cmd
 
-type NetworkChecker interface {
-	CheckConnectivity() bool
+import (
+	"github.com/spf13/cobra"
 
-	GetBandwidth() string
+	"github.com/iomesh/debugtool/pkg/checker"
+)
 
-	GetLatencyMS() int
+var diskCmd = &cobra.Command{
+	Use:   "disk",
+	Short: "Find the block devices of every node IOMesh can use and optionally benchmark them",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runChecks(checker.CategoryDisk)
+	},
+}
 
-	GetPacketLossRate() float32
+func init() {
+	rootCmd.AddCommand(diskCmd)
 }
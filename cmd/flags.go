@@ -0,0 +1,73 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/iomesh/debugtool/pkg/config"
+)
+
+// init binds the settings of the checks to persistent flags, so they can be
+// given to the root command as well as to the subcommands.
+func init() {
+	cfg := config.Get()
+	flags := rootCmd.PersistentFlags()
+
+	flags.StringVar(&cfg.DataCIDR, "data-cidr", cfg.DataCIDR,
+		"CIDR of the IOMesh data network, an IPv4 and an IPv6 CIDR separated by a comma for a dual-stack network, defaults to $IOMESH_DATA_CIDR. Discovered from the node interfaces when empty or \"auto\"")
+	flags.Float64Var(&cfg.MaxLatencyMS, "max-latency-ms", cfg.MaxLatencyMS,
+		"Average round trip time in milliseconds above which a node pair is flagged")
+	flags.StringVar(&cfg.LossRate, "loss-rate", cfg.LossRate,
+		"UDP send rate of the packet loss tests, such as 500M or 1G")
+	flags.Float64Var(&cfg.WarnLossPercent, "warn-loss-percent", cfg.WarnLossPercent,
+		"Packet loss in percent above which a node pair is flagged with a warning")
+	flags.Float64Var(&cfg.MaxLossPercent, "max-loss-percent", cfg.MaxLossPercent,
+		"Packet loss in percent above which a node pair fails")
+	flags.Float64Var(&cfg.MinLinkUtilizationPercent, "min-link-utilization-percent", cfg.MinLinkUtilizationPercent,
+		"Measured bandwidth in percent of the link speed below which a node is flagged")
+	flags.IntSliceVar(&cfg.Ports, "ports", cfg.Ports,
+		"TCP ports IOMesh needs free and reachable on every node")
+	flags.Float64Var(&cfg.MaxClockSkewMS, "max-clock-skew-ms", cfg.MaxClockSkewMS,
+		"Clock difference in milliseconds between nodes or a node and the API server above which the clock check fails")
+	flags.StringVar(&cfg.CompatibilityFile, "compatibility-file", cfg.CompatibilityFile,
+		"YAML file overriding the built-in OS compatibility matrix of the node checks")
+	flags.StringVar(&cfg.DeploymentSize, "deployment-size", cfg.DeploymentSize,
+		"Size of the IOMesh deployment, small, medium or large, selecting the resources expected free on every node")
+	flags.StringVar(&cfg.FootprintFile, "footprint-file", cfg.FootprintFile,
+		"YAML file of IOMesh resource footprints by deployment size overriding the built-in ones")
+	flags.StringVar(&cfg.TunablesFile, "tunables-file", cfg.TunablesFile,
+		"YAML file of expected hugepage and sysctl values overriding or extending the built-in ones")
+	flags.BoolVar(&cfg.DiskBenchmark, "disk-benchmark", cfg.DiskBenchmark,
+		"Benchmark the disks of every node with fio, it keeps them busy for a few minutes")
+	flags.StringSliceVar(&cfg.BenchmarkDevices, "benchmark-devices", cfg.BenchmarkDevices,
+		"Devices to benchmark, such as /dev/sdb or node1:/dev/sdb, only read unless --benchmark-destructive is set. A scratch file is benchmarked when empty")
+	flags.BoolVar(&cfg.BenchmarkDestructive, "benchmark-destructive", cfg.BenchmarkDestructive,
+		"Also run the write profiles on --benchmark-devices, destroying their content. Only eligible empty disks are written")
+	flags.StringVar(&cfg.BenchmarkDir, "benchmark-dir", cfg.BenchmarkDir,
+		"Host directory of the scratch file of the disk benchmark")
+	flags.StringVar(&cfg.BenchmarkFileSize, "benchmark-file-size", cfg.BenchmarkFileSize,
+		"Size of the scratch file of the disk benchmark, such as 2G")
+	flags.IntVar(&cfg.BenchmarkRuntimeSeconds, "benchmark-runtime-seconds", cfg.BenchmarkRuntimeSeconds,
+		"Duration in seconds of each fio profile of the disk benchmark")
+	flags.Float64Var(&cfg.MinRandReadIOPS, "min-rand-read-iops", cfg.MinRandReadIOPS,
+		"4k random read IOPS below which a disk fails the benchmark")
+	flags.Float64Var(&cfg.MinRandWriteIOPS, "min-rand-write-iops", cfg.MinRandWriteIOPS,
+		"4k random write IOPS below which a disk fails the benchmark")
+	flags.Float64Var(&cfg.MinSeqReadMBps, "min-seq-read-mbps", cfg.MinSeqReadMBps,
+		"1M sequential read throughput in MB/s below which a disk fails the benchmark")
+	flags.Float64Var(&cfg.MinSeqWriteMBps, "min-seq-write-mbps", cfg.MinSeqWriteMBps,
+		"1M sequential write throughput in MB/s below which a disk fails the benchmark")
+	flags.Float64Var(&cfg.MaxSyncWriteLatencyMS, "max-sync-write-latency-ms", cfg.MaxSyncWriteLatencyMS,
+		"Average 4k synchronous write latency in milliseconds above which a disk fails the benchmark")
+}
@@ -15,26 +15,16 @@ limitations under the License.
 package cmd
 
 import (
-	"fmt"
-
-	"github.com/enescakir/emoji"
 	"github.com/spf13/cobra"
 
-	"github.com/iomesh/debugtool/pkg/infra/dns"
+	"github.com/iomesh/debugtool/pkg/checker"
 )
 
 var infraCmd = &cobra.Command{
 	Use:   "infra",
 	Short: "Verify infra service such as DNS working well",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		fmt.Printf("InfraService %v\n", emoji.Joystick)
-		dnsChecker := dns.NewDNSChecker()
-		if err := dnsChecker.Check(); err != nil {
-			return fmt.Errorf("Check dns fail: %v", err)
-		}
-		fmt.Println("")
-
-		return nil
+		return runChecks(checker.CategoryInfra)
 	},
 }
 
@@ -15,13 +15,9 @@ limitations under the License.
 package cmd
 
 import (
-	"fmt"
-
-	"github.com/enescakir/emoji"
 	"github.com/spf13/cobra"
 
-	"github.com/iomesh/debugtool/pkg/network/cni"
-	"github.com/iomesh/debugtool/pkg/network/hostnetwork"
+	"github.com/iomesh/debugtool/pkg/checker"
 )
 
 // networkCmd represents the network command
@@ -29,19 +25,7 @@ var networkCmd = &cobra.Command{
 	Use:   "network",
 	Short: "Verify connectivity and bandwidth of cni and hostnetwork",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		fmt.Printf("Network %v\n", emoji.ElectricPlug)
-		cniChecker := cni.NewCNIChecker()
-		if err := cniChecker.CheckConnectivity(); err != nil {
-			return err
-		}
-
-		hostNetworkChecker := hostnetwork.NewHostNetworkChecker()
-		if err := hostNetworkChecker.GetBandwidth(); err != nil {
-			return err
-		}
-		fmt.Println("")
-
-		return nil
+		return runChecks(checker.CategoryNetwork)
 	},
 }
 
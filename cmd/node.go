@@ -0,0 +1,33 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+)
+
+var nodeCmd = &cobra.Command{
+	Use:   "node",
+	Short: "Verify the OS, kernel and host services of every node meet the IOMesh requirements",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runChecks(checker.CategoryNode)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(nodeCmd)
+}
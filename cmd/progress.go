@@ -0,0 +1,88 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/briandowns/spinner"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+	"github.com/iomesh/debugtool/pkg/report"
+)
+
+// spinnerProgress shows a spinner while a check is running and its status
+// once it finished.
+type spinnerProgress struct {
+	spinner      *spinner.Spinner
+	lastCategory checker.Category
+}
+
+func newSpinnerProgress() *spinnerProgress {
+	return &spinnerProgress{
+		spinner: spinner.New(spinner.CharSets[7], 100*time.Millisecond),
+	}
+}
+
+func (p *spinnerProgress) Start(check checker.Check) {
+	if check.Category() != p.lastCategory {
+		if p.lastCategory != "" {
+			fmt.Println("")
+		}
+		fmt.Println(report.CategoryTitle(check.Category()))
+		p.lastCategory = check.Category()
+	}
+	p.spinner.Suffix = " " + check.Description()
+	p.spinner.Start()
+}
+
+func (p *spinnerProgress) Finish(check checker.Check, result checker.Result) {
+	p.spinner.FinalMSG = fmt.Sprintf("%v %s\n", report.StatusEmoji(result.Status), check.Description())
+	p.spinner.Stop()
+}
+
+// plainProgress prints one line per finished check, for text output that is
+// not written to a terminal.
+type plainProgress struct {
+	lastCategory checker.Category
+}
+
+func (p *plainProgress) Start(check checker.Check) {
+	if check.Category() != p.lastCategory {
+		if p.lastCategory != "" {
+			fmt.Println("")
+		}
+		fmt.Println(report.CategoryTitle(check.Category()))
+		p.lastCategory = check.Category()
+	}
+}
+
+func (p *plainProgress) Finish(check checker.Check, result checker.Result) {
+	fmt.Printf("%v %s\n", report.StatusEmoji(result.Status), check.Description())
+}
+
+// newProgress returns the progress matching the output format, or nil when
+// nothing but the report may be written to stdout.
+func newProgress(format string) checker.Progress {
+	switch {
+	case format != report.FormatText:
+		return nil
+	case checker.Interactive():
+		return newSpinnerProgress()
+	default:
+		return &plainProgress{}
+	}
+}
@@ -15,47 +15,148 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/mattn/go-isatty"
 	"github.com/spf13/cobra"
 
+	"github.com/iomesh/debugtool/pkg/checker"
 	"github.com/iomesh/debugtool/pkg/fixture"
+	"github.com/iomesh/debugtool/pkg/report"
 )
 
 var f = fixture.GetInstance()
 
+var (
+	listChecks bool
+	onlyChecks []string
+	skipChecks []string
+
+	outputFormat string
+	junitReport  string
+	htmlReport   string
+
+	// checksFailed is set when any check failed. The process exits non-zero
+	// only after every check has run and the environment was cleaned up.
+	checksFailed bool
+)
+
 var rootCmd = &cobra.Command{
 	Use: "debug",
 	Long: `IOMesh debugtool is used to detect whether the k8s environment meets
 the installation conditions before installing IOMesh.`,
 
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-		if cmd.Name() == "help" {
+		if !isValidFormat(outputFormat) {
+			return fmt.Errorf("Unknown output format %s, must be one of %s", outputFormat, strings.Join(report.Formats, ", "))
+		}
+		checker.SetInteractive(outputFormat == report.FormatText && isatty.IsTerminal(os.Stdout.Fd()))
+
+		if cmd.Name() == "help" || listChecks {
 			return nil
 		}
-		return f.EnsureBasicDsDeployed()
-	},
-	RunE: func(cmd *cobra.Command, args []string) error {
-		if err := networkCmd.RunE(cmd, args); err != nil {
+		if err := checker.Connect(); err != nil {
 			return err
 		}
-		if err := infraCmd.RunE(cmd, args); err != nil {
+		// the disk checks only use the node checker daemonset, they
+		// don't need the data network
+		if cmd == discoverCmd || cmd == diskCmd {
+			return nil
+		}
+		if err := resolveDataCIDR(context.Background()); err != nil {
 			return err
 		}
-		return nil
+		return f.EnsureBasicDsDeployed()
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runChecks("")
 	},
 	PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
-		if cmd.Name() == "help" {
+		if cmd.Name() == "help" || listChecks {
 			return nil
 		}
 		return f.Cleanup()
 	},
 }
 
+// runChecks runs the registered checks of the given category, or of every
+// category if empty, honoring the --checks and --skip flags.
+func runChecks(category checker.Category) error {
+	checks, err := checker.Select(category, onlyChecks, skipChecks)
+	if err != nil {
+		return err
+	}
+
+	if listChecks {
+		for _, check := range checks {
+			deps := ""
+			if len(check.Dependencies()) > 0 {
+				deps = fmt.Sprintf(" (depends on %s)", strings.Join(check.Dependencies(), ", "))
+			}
+			fmt.Printf("%-10s %s%s\n", check.Category(), check.Name(), deps)
+		}
+		return nil
+	}
+
+	ctx := context.Background()
+	r := report.New()
+	cluster, err := report.GetClusterInfo(ctx, f.Checker)
+	if err != nil {
+		f.Log.Error(err, "Collect cluster info fail")
+	}
+	r.Cluster = cluster
+
+	r.Results = checker.Run(ctx, checks, newProgress(outputFormat))
+	r.Duration = time.Since(r.StartTime)
+	if outputFormat == report.FormatText {
+		fmt.Println("")
+	}
+
+	if r.Status() == checker.StatusFail {
+		checksFailed = true
+	}
+	if junitReport != "" {
+		if err := report.WriteFile(junitReport, report.FormatJUnit, r); err != nil {
+			return err
+		}
+	}
+	if htmlReport != "" {
+		if err := report.WriteFile(htmlReport, report.FormatHTML, r); err != nil {
+			return err
+		}
+	}
+	return report.Write(os.Stdout, outputFormat, r)
+}
+
+func isValidFormat(format string) bool {
+	for _, valid := range report.Formats {
+		if valid == format {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", report.FormatText,
+		fmt.Sprintf("Output format of the report, one of %s", strings.Join(report.Formats, ", ")))
+	rootCmd.PersistentFlags().StringVar(&junitReport, "junit-report", "", "Also write the report as JUnit XML to the given file")
+	rootCmd.PersistentFlags().StringVar(&htmlReport, "html-report", "", "Also write the report as a self-contained HTML page to the given file")
+	rootCmd.PersistentFlags().BoolVar(&listChecks, "list", false, "List the checks that would be run and exit")
+	rootCmd.PersistentFlags().StringSliceVar(&onlyChecks, "checks", nil, "Only run the given checks (and their dependencies)")
+	rootCmd.PersistentFlags().StringSliceVar(&skipChecks, "skip", nil, "Do not run the given checks")
+}
+
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if checksFailed {
 		os.Exit(1)
 	}
 }
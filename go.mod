@@ -9,6 +9,7 @@ require (
 	github.com/enescakir/emoji v1.0.0
 	github.com/go-logr/logr v0.4.0
 	github.com/iomesh/operator v0.9.8
+	github.com/mattn/go-isatty v0.0.12
 	github.com/spf13/cobra v1.1.1
 	k8s.io/api v0.20.2
 	k8s.io/apimachinery v0.20.2
@@ -16,4 +17,5 @@ require (
 	k8s.io/client-go v12.0.0+incompatible
 	k8s.io/kubectl v0.20.2
 	sigs.k8s.io/controller-runtime v0.6.2
+	sigs.k8s.io/yaml v1.2.0
 )
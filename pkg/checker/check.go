@@ -0,0 +1,62 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checker
+
+import "context"
+
+// Category groups checks by the subcommand that runs them.
+type Category string
+
+const (
+	CategoryNetwork Category = "network"
+	CategoryInfra   Category = "infra"
+	CategoryNode    Category = "node"
+	CategoryDisk    Category = "disk"
+)
+
+// Categories lists every category in the order checks are run.
+var Categories = []Category{
+	CategoryNetwork,
+	CategoryInfra,
+	CategoryNode,
+	CategoryDisk,
+}
+
+// Check is a single preflight check. Checks register themselves with
+// Register and are discovered by the commands through the registry.
+type Check interface {
+	// Name uniquely identifies the check, e.g. "cni-connectivity".
+	Name() string
+
+	Category() Category
+
+	// Description is a short human readable summary of what the check
+	// does, e.g. "Checking CNI connectivity".
+	Description() string
+
+	// Dependencies returns the names of the checks that must pass
+	// before this one is run.
+	Dependencies() []string
+
+	Run(ctx context.Context) Result
+}
+
+// Orderer is implemented by checks that use what other checks measured when
+// those run in the same invocation, without depending on them. Unlike
+// dependencies, the checks returned by After are neither added to the
+// selection nor required to pass, they are only run first when selected.
+type Orderer interface {
+	After() []string
+}
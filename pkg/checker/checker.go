@@ -17,7 +17,7 @@ package checker
 import (
 	"bytes"
 	"fmt"
-	"os"
+	"sync"
 	"time"
 
 	"github.com/briandowns/spinner"
@@ -35,47 +35,92 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 )
 
-type Checker struct {
-	Client  client.Client
-	Log     logr.Logger
-	Spinner *spinner.Spinner
+// Clients are the clients of the cluster shared by every checker. They are
+// set up by Connect rather than when the checkers are created, so the check
+// packages can be loaded, listed and unit tested without a cluster.
+type Clients struct {
+	Client client.Client
 
 	// using for run cmd in pod
 	PodExecConfig *rest.Config
 	ClientSet     *kubernetes.Clientset
 }
 
+type Checker struct {
+	*Clients
+	Log     logr.Logger
+	Spinner *spinner.Spinner
+}
+
+// interactive is false when the output is not a terminal or is meant to be
+// parsed by a machine, in which case no spinner should be shown.
+var interactive = true
+
+func SetInteractive(enabled bool) {
+	interactive = enabled
+}
+
+func Interactive() bool {
+	return interactive
+}
+
+var (
+	connectOnce sync.Once
+	connectErr  error
+	// every checker talks to the same cluster, so the clients are only
+	// created once and shared
+	sharedClients = &Clients{}
+)
+
 func Newchecker(LoggerName string) Checker {
-	checker := Checker{}
+	checker := Checker{
+		Clients: sharedClients,
+	}
 	log.SetLogger(zap.New())
 	checker.Log = ctrl.Log.WithName(LoggerName)
+	checker.Spinner = spinner.New(spinner.CharSets[7], 100*time.Millisecond)
+
+	return checker
+}
+
+// Connect creates the clients shared by every checker from the kubeconfig. It
+// must be called before any check runs.
+func Connect() error {
+	connectOnce.Do(func() {
+		connectErr = connect()
+	})
+	return connectErr
+}
 
-	k8sClient, err := client.New(config.GetConfigOrDie(), client.Options{
+func connect() error {
+	restConfig, err := config.GetConfig()
+	if err != nil {
+		return fmt.Errorf("Load kubeconfig: %v", err)
+	}
+	k8sClient, err := client.New(restConfig, client.Options{
 		Scheme: scheme.Scheme,
 	})
 	if err != nil {
-		checker.Log.Error(err, "Connect to k8s cluster fail")
-		os.Exit(1)
+		return fmt.Errorf("Connect to k8s cluster: %v", err)
 	}
-	checker.Client = k8sClient
-
-	checker.Spinner = spinner.New(spinner.CharSets[7], 100*time.Millisecond)
 
 	// using for run cmd in pod
-	checker.PodExecConfig = config.GetConfigOrDie()
-	checker.PodExecConfig.GroupVersion = &schema.GroupVersion{
+	podExecConfig := rest.CopyConfig(restConfig)
+	podExecConfig.GroupVersion = &schema.GroupVersion{
 		Version: "v1",
 	}
-	checker.PodExecConfig.APIPath = "/api"
-	checker.PodExecConfig.NegotiatedSerializer = scheme.Codecs.WithoutConversion()
+	podExecConfig.APIPath = "/api"
+	podExecConfig.NegotiatedSerializer = scheme.Codecs.WithoutConversion()
 
-	checker.ClientSet, err = kubernetes.NewForConfig(checker.PodExecConfig)
+	clientSet, err := kubernetes.NewForConfig(podExecConfig)
 	if err != nil {
-		checker.Log.Error(err, "create clientset fail")
-		os.Exit(1)
+		return fmt.Errorf("Create clientset: %v", err)
 	}
 
-	return checker
+	sharedClients.Client = k8sClient
+	sharedClients.PodExecConfig = podExecConfig
+	sharedClients.ClientSet = clientSet
+	return nil
 }
 
 func (c Checker) RunCmdInPod(podName string, namespace string, cmd string) (string, error) {
@@ -0,0 +1,167 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checker
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	registryLock sync.Mutex
+	registry     = map[string]Check{}
+)
+
+// Register adds a check to the registry. It is meant to be called from the
+// init function of the package implementing the check.
+func Register(check Check) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	if _, ok := registry[check.Name()]; ok {
+		panic(fmt.Sprintf("check %s registered twice", check.Name()))
+	}
+	registry[check.Name()] = check
+}
+
+// List returns the registered checks of the given category, or every check
+// if category is empty, ordered by category and then by name.
+func List(category Category) []Check {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	checks := []Check{}
+	for _, check := range registry {
+		if category == "" || check.Category() == category {
+			checks = append(checks, check)
+		}
+	}
+	sort.Slice(checks, func(i, j int) bool {
+		ci, cj := categoryIndex(checks[i].Category()), categoryIndex(checks[j].Category())
+		if ci != cj {
+			return ci < cj
+		}
+		return checks[i].Name() < checks[j].Name()
+	})
+	return checks
+}
+
+// Select returns the checks of the given category filtered by name. An empty
+// only list selects every check of the category. Dependencies of the selected
+// checks are added and the result is ordered so that every check comes after
+// its dependencies, and after the selected checks it is ordered after by
+// Orderer.
+func Select(category Category, only, skip []string) ([]Check, error) {
+	registryLock.Lock()
+	for _, name := range append(append([]string{}, only...), skip...) {
+		if _, ok := registry[name]; !ok {
+			registryLock.Unlock()
+			return nil, fmt.Errorf("Unknown check %s", name)
+		}
+	}
+	registryLock.Unlock()
+
+	skipped := map[string]bool{}
+	for _, name := range skip {
+		skipped[name] = true
+	}
+	wanted := map[string]bool{}
+	for _, name := range only {
+		wanted[name] = true
+	}
+
+	selected := map[string]bool{}
+	for _, check := range List(category) {
+		if skipped[check.Name()] || (len(wanted) > 0 && !wanted[check.Name()]) {
+			continue
+		}
+		selected[check.Name()] = true
+	}
+	if len(wanted) > 0 {
+		// checks of other categories may be requested explicitly by name
+		for name := range wanted {
+			selected[name] = true
+		}
+	}
+
+	ordered := []Check{}
+	visiting := map[string]bool{}
+	visited := map[string]bool{}
+	var visit func(check Check) error
+	visit = func(check Check) error {
+		name := check.Name()
+		if visited[name] {
+			return nil
+		}
+		if visiting[name] {
+			return fmt.Errorf("Dependency cycle detected at check %s", name)
+		}
+		visiting[name] = true
+		for _, dep := range check.Dependencies() {
+			depCheck, ok := get(dep)
+			if !ok {
+				return fmt.Errorf("Check %s depends on unknown check %s", name, dep)
+			}
+			if err := visit(depCheck); err != nil {
+				return err
+			}
+		}
+		if orderer, ok := check.(Orderer); ok {
+			for _, before := range orderer.After() {
+				beforeCheck, ok := get(before)
+				if !ok {
+					return fmt.Errorf("Check %s is ordered after unknown check %s", name, before)
+				}
+				if !selected[before] {
+					continue
+				}
+				if err := visit(beforeCheck); err != nil {
+					return err
+				}
+			}
+		}
+		visiting[name] = false
+		visited[name] = true
+		ordered = append(ordered, check)
+		return nil
+	}
+	for _, check := range List("") {
+		if !selected[check.Name()] {
+			continue
+		}
+		if err := visit(check); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+func get(name string) (Check, bool) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	check, ok := registry[name]
+	return check, ok
+}
+
+func categoryIndex(category Category) int {
+	for i, c := range Categories {
+		if c == category {
+			return i
+		}
+	}
+	return len(Categories)
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checker
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+type fakeCheck struct {
+	name  string
+	deps  []string
+	after []string
+}
+
+func (c fakeCheck) Name() string                   { return c.name }
+func (c fakeCheck) Category() Category             { return CategoryNetwork }
+func (c fakeCheck) Description() string            { return c.name }
+func (c fakeCheck) Dependencies() []string         { return c.deps }
+func (c fakeCheck) Run(ctx context.Context) Result { return Result{Status: StatusPass} }
+
+type fakeOrderedCheck struct {
+	fakeCheck
+}
+
+func (c fakeOrderedCheck) After() []string { return c.after }
+
+func TestSelect(t *testing.T) {
+	// a-nic is ordered after z-bandwidth, which sorts after it by name,
+	// and m-latency depends on z-bandwidth
+	Register(fakeOrderedCheck{fakeCheck{name: "a-nic", after: []string{"z-bandwidth"}}})
+	Register(fakeCheck{name: "m-latency", deps: []string{"z-bandwidth"}})
+	Register(fakeCheck{name: "z-bandwidth"})
+
+	tests := []struct {
+		only, skip []string
+		want       []string
+		wantErr    bool
+	}{
+		{want: []string{"z-bandwidth", "a-nic", "m-latency"}},
+		{only: []string{"a-nic"}, want: []string{"a-nic"}},
+		{only: []string{"m-latency"}, want: []string{"z-bandwidth", "m-latency"}},
+		{only: []string{"a-nic", "z-bandwidth"}, want: []string{"z-bandwidth", "a-nic"}},
+		{skip: []string{"z-bandwidth"}, want: []string{"a-nic", "z-bandwidth", "m-latency"}},
+		{only: []string{"unknown"}, wantErr: true},
+	}
+	for i, tt := range tests {
+		checks, err := Select(CategoryNetwork, tt.only, tt.skip)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("test %d: err = %v, want error %v", i, err, tt.wantErr)
+		}
+		names := []string{}
+		for _, check := range checks {
+			names = append(names, check.Name())
+		}
+		if !tt.wantErr && !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("test %d: Select(%v, %v) = %v, want %v", i, tt.only, tt.skip, names, tt.want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checker
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+	StatusSkip Status = "skip"
+)
+
+var statusSeverity = map[Status]int{
+	"":         -1,
+	StatusPass: 0,
+	StatusSkip: 1,
+	StatusWarn: 2,
+	StatusFail: 3,
+}
+
+// Worse returns the more severe of the two statuses.
+func Worse(a, b Status) Status {
+	if statusSeverity[b] > statusSeverity[a] {
+		return b
+	}
+	return a
+}
+
+// Measurement is a single measured value, such as the bandwidth between two
+// nodes.
+type Measurement struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit,omitempty"`
+}
+
+// SubResult is the outcome of a check for a single node or node pair, or for
+// a Kubernetes object.
+type SubResult struct {
+	// Node is the node the sub-result belongs to, or the source node when
+	// the sub-result describes a node pair. It is empty or the node the
+	// object runs on when the sub-result describes an object.
+	Node string `json:"node"`
+	// Peer is the destination node of a node pair, empty otherwise.
+	Peer string `json:"peer,omitempty"`
+	// Family is the address family, IPv4 or IPv6, the sub-result was
+	// measured over when a check measures each family of a dual-stack
+	// network separately.
+	Family string `json:"family,omitempty"`
+	// Device is the block device of the node the sub-result belongs to,
+	// such as /dev/sdb, empty for sub-results about the whole node.
+	Device string `json:"device,omitempty"`
+	// Object is the Kubernetes object or component the sub-result belongs
+	// to when it isn't about a node, such as deployment/coredns or
+	// api-server.
+	Object string `json:"object,omitempty"`
+
+	Status       Status        `json:"status"`
+	Message      string        `json:"message,omitempty"`
+	Measurements []Measurement `json:"measurements,omitempty"`
+	// Details holds check specific raw data, such as the per interval
+	// samples of a bandwidth test, for the machine readable reports.
+	Details interface{} `json:"details,omitempty"`
+}
+
+// Name returns "node", "node:device" for devices, "object" or "object@node"
+// for objects or "node -> peer" for node pairs, followed by the address family
+// if any.
+func (sr SubResult) Name() string {
+	name := sr.Node
+	switch {
+	case sr.Object != "" && sr.Node != "":
+		name = fmt.Sprintf("%s@%s", sr.Object, sr.Node)
+	case sr.Object != "":
+		name = sr.Object
+	case sr.Device != "":
+		name = fmt.Sprintf("%s:%s", sr.Node, sr.Device)
+	}
+	if sr.Peer != "" {
+		name = fmt.Sprintf("%s -> %s", sr.Node, sr.Peer)
+	}
+	if sr.Family != "" {
+		name = fmt.Sprintf("%s (%s)", name, sr.Family)
+	}
+	return name
+}
+
+// Result is the outcome of running a single Check.
+type Result struct {
+	Name     string   `json:"name"`
+	Category Category `json:"category"`
+	Status   Status   `json:"status"`
+	Message  string   `json:"message,omitempty"`
+	// Remediation hints at how to fix the environment when the check does
+	// not pass.
+	Remediation  string        `json:"remediation,omitempty"`
+	Duration     time.Duration `json:"-"`
+	Measurements []Measurement `json:"measurements,omitempty"`
+	SubResults   []SubResult   `json:"subResults,omitempty"`
+}
+
+// MarshalJSON encodes the duration in seconds rather than nanoseconds so the
+// machine readable reports don't depend on Go's time.Duration.
+func (r Result) MarshalJSON() ([]byte, error) {
+	type result Result
+	return json.Marshal(struct {
+		result
+		DurationSeconds float64 `json:"durationSeconds"`
+	}{
+		result:          result(r),
+		DurationSeconds: r.Duration.Seconds(),
+	})
+}
+
+// Failf marks the result failed with a formatted message.
+func (r *Result) Failf(format string, args ...interface{}) {
+	r.Status = StatusFail
+	r.Message = fmt.Sprintf(format, args...)
+}
+
+// Warnf downgrades the result to a warning with a formatted message, unless
+// it already failed.
+func (r *Result) Warnf(format string, args ...interface{}) {
+	if r.Status == StatusFail {
+		return
+	}
+	r.Status = StatusWarn
+	r.Message = fmt.Sprintf(format, args...)
+}
+
+// AddSubResult appends a sub-result and raises the status of the result to
+// the status of the sub-result if that is worse.
+func (r *Result) AddSubResult(sr SubResult) {
+	r.SubResults = append(r.SubResults, sr)
+	r.Status = Worse(r.Status, sr.Status)
+}
+
+// Count returns the number of sub-results with the given status.
+func (r Result) Count(status Status) int {
+	count := 0
+	for _, sr := range r.SubResults {
+		if sr.Status == status {
+			count++
+		}
+	}
+	return count
+}
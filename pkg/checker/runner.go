@@ -0,0 +1,73 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checker
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Progress is notified before and after every check run by Run.
+type Progress interface {
+	Start(check Check)
+	Finish(check Check, result Result)
+}
+
+// Run runs every check in order and returns all results, even when some of
+// them fail. A check whose dependency failed or was skipped is skipped too.
+// progress may be nil.
+func Run(ctx context.Context, checks []Check, progress Progress) []Result {
+	results := []Result{}
+	statuses := map[string]Status{}
+	for _, check := range checks {
+		if progress != nil {
+			progress.Start(check)
+		}
+
+		start := time.Now()
+		var result Result
+		if dep := blockingDependency(check, statuses); dep != "" {
+			result = Result{
+				Status:  StatusSkip,
+				Message: fmt.Sprintf("Dependency %s did not pass", dep),
+			}
+		} else {
+			result = check.Run(ctx)
+		}
+		result.Name = check.Name()
+		result.Category = check.Category()
+		result.Duration = time.Since(start)
+		if result.Status == "" {
+			result.Status = StatusPass
+		}
+		statuses[check.Name()] = result.Status
+
+		if progress != nil {
+			progress.Finish(check, result)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func blockingDependency(check Check, statuses map[string]Status) string {
+	for _, dep := range check.Dependencies() {
+		if status := statuses[dep]; status == StatusFail || status == StatusSkip {
+			return dep
+		}
+	}
+	return ""
+}
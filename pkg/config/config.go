@@ -0,0 +1,165 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package config
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// Config holds the settings of the checks. The commands bind their flags to
+// the fields of the global config, checks read it when they run.
+type Config struct {
+	// DataCIDR is the CIDR of the IOMesh data network, or a comma
+	// separated IPv4 and IPv6 CIDR for a dual-stack data network. Empty or
+	// DataCIDRAuto means it is discovered from the interfaces of the nodes.
+	DataCIDR string
+
+	// MaxLatencyMS is the average round trip time above which a node
+	// pair is flagged.
+	MaxLatencyMS float64
+
+	// LossRate is the UDP send rate of the packet loss tests, in iperf3
+	// -b syntax.
+	LossRate string
+	// WarnLossPercent and MaxLossPercent are the packet loss above which a
+	// node pair is flagged with a warning or fails.
+	WarnLossPercent float64
+	MaxLossPercent  float64
+
+	// MinLinkUtilizationPercent is the share of the negotiated link speed
+	// of the data network interface below which the measured bandwidth of a
+	// node is flagged.
+	MinLinkUtilizationPercent float64
+
+	// Ports are the TCP ports IOMesh listens on in the host network of
+	// every storage node.
+	Ports []int
+
+	// MaxClockSkewMS is the clock difference between two nodes, or a node
+	// and the API server, above which the clock check fails.
+	MaxClockSkewMS float64
+
+	// CompatibilityFile is a YAML file overriding the fields of the built-in
+	// OS compatibility matrix, empty to use the built-in one.
+	CompatibilityFile string
+
+	// DeploymentSize selects the footprint profile, small, medium or large,
+	// the node capacity check expects the IOMesh storage components to
+	// request on every node. FootprintFile is a YAML file of footprint
+	// profiles by deployment size overriding the built-in ones.
+	DeploymentSize string
+	FootprintFile  string
+
+	// TunablesFile is a YAML file of expected hugepage, transparent
+	// hugepage and sysctl values, replacing the built-in expectations of
+	// the same keys and adding the others.
+	TunablesFile string
+
+	// DiskBenchmark enables the fio benchmark of the disk command, it keeps
+	// the disks of every node busy for a few minutes.
+	DiskBenchmark bool
+	// BenchmarkDevices are the devices to benchmark, such as /dev/sdb on
+	// every node or node1:/dev/sdb on a single node. A scratch file in
+	// BenchmarkDir is benchmarked instead when empty.
+	BenchmarkDevices []string
+	// BenchmarkDestructive runs the write profiles on BenchmarkDevices too,
+	// overwriting them, they must then be eligible for IOMesh. Only the
+	// read profiles run on devices otherwise.
+	BenchmarkDestructive bool
+	// BenchmarkDir is the host directory of the scratch file and
+	// BenchmarkFileSize its size in fio syntax.
+	BenchmarkDir      string
+	BenchmarkFileSize string
+	// BenchmarkRuntimeSeconds is how long each fio profile runs.
+	BenchmarkRuntimeSeconds int
+	// MinRandReadIOPS, MinRandWriteIOPS, MinSeqReadMBps and MinSeqWriteMBps
+	// are the 4k random IOPS and 1M sequential throughput below which a
+	// disk fails the benchmark, MaxSyncWriteLatencyMS is the average
+	// latency of 4k synchronous writes above which it fails.
+	MinRandReadIOPS       float64
+	MinRandWriteIOPS      float64
+	MinSeqReadMBps        float64
+	MinSeqWriteMBps       float64
+	MaxSyncWriteLatencyMS float64
+}
+
+// DataCIDRAuto asks for the data network to be discovered.
+const DataCIDRAuto = "auto"
+
+// DefaultPorts are the ports of zookeeper, the iSCSI target, the meta, chunk
+// and task services of IOMesh.
+var DefaultPorts = []int{2181, 2888, 3888, 3260, 10100, 10101, 10102, 10200, 10201, 10206, 10600, 10601}
+
+var global = Default()
+
+func Default() *Config {
+	return &Config{
+		DataCIDR:        os.Getenv("IOMESH_DATA_CIDR"),
+		MaxLatencyMS:    1,
+		LossRate:        "500M",
+		WarnLossPercent: 0.1,
+		MaxLossPercent:  1,
+
+		MinLinkUtilizationPercent: 30,
+
+		Ports: DefaultPorts,
+
+		MaxClockSkewMS: 500,
+
+		DeploymentSize: "small",
+
+		BenchmarkDir:            "/var/tmp",
+		BenchmarkFileSize:       "2G",
+		BenchmarkRuntimeSeconds: 15,
+		MinRandReadIOPS:         5000,
+		MinRandWriteIOPS:        2000,
+		MinSeqReadMBps:          200,
+		MinSeqWriteMBps:         150,
+		MaxSyncWriteLatencyMS:   2,
+	}
+}
+
+// Get returns the global config.
+func Get() *Config {
+	return global
+}
+
+// DataCIDRUnset tells whether the data network must be discovered.
+func (c *Config) DataCIDRUnset() bool {
+	return c.DataCIDR == "" || c.DataCIDR == DataCIDRAuto
+}
+
+// DataCIDRs parses DataCIDR into one network per address family, the first
+// one being the primary family of the data network.
+func (c *Config) DataCIDRs() ([]*net.IPNet, error) {
+	networks := []*net.IPNet{}
+	families := map[bool]bool{}
+	for _, cidr := range strings.Split(c.DataCIDR, ",") {
+		_, network, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			return nil, fmt.Errorf("Data CIDR %q is not the correct cidr format. example: IOMESH_DATA_CIDR=192.168.1.0/24 or IOMESH_DATA_CIDR=192.168.1.0/24,fd00:1::/64", c.DataCIDR)
+		}
+		ipv4 := network.IP.To4() != nil
+		if families[ipv4] {
+			return nil, fmt.Errorf("Data CIDR %q has more than one CIDR of the same address family", c.DataCIDR)
+		}
+		families[ipv4] = true
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
@@ -20,10 +20,41 @@ const (
 	DebugNamespace           = "iomesh-debug"
 	BasicCheckerDSName       = "basic-checker"
 	HostNetworkCheckerDSName = "hostnetwork-checker"
+	NodeCheckerDSName        = "node-checker"
 	DebugToolsImage          = "iomesh/debugtools:latest"
+	DebugServiceName         = "iomesh-debug"
+	DebugHeadlessServiceName = "iomesh-debug-headless"
+
+	// IperfPort is where the iperf3 servers of the debug pods listen.
+	IperfPort = 5201
 
 	BasicCheckerLabel       = "iomesh-debug-basic"
 	HostNetworkCheckerLabel = "iomesh-debug-hostnetwork"
+	NodeCheckerLabel        = "iomesh-debug-node"
+
+	// HostRootPath is where the node checker pods mount the root
+	// filesystem of their node.
+	HostRootPath = "/host"
+
+	CNIConnectivityCheckName      = "cni-connectivity"
+	HostNetworkBandwidthCheckName = "hostnetwork-bandwidth"
+	HostNetworkLatencyCheckName   = "hostnetwork-latency"
+	CNILatencyCheckName           = "cni-latency"
+	HostNetworkLossCheckName      = "hostnetwork-packet-loss"
+	CNILossCheckName              = "cni-packet-loss"
+	HostNetworkMTUCheckName       = "hostnetwork-mtu"
+	HostNetworkNICCheckName       = "hostnetwork-nic"
+	HostNetworkPortsCheckName     = "hostnetwork-ports"
+	ServiceDatapathCheckName      = "service-datapath"
+	DNSCheckName                  = "dns"
+	DNSDeploymentCheckName        = "dns-deployment"
+	ClockSkewCheckName            = "clock-skew"
+	OSCompatibilityCheckName      = "os-compatibility"
+	ISCSIInitiatorCheckName       = "iscsi-initiator"
+	NodeCapacityCheckName         = "node-capacity"
+	KernelTunablesCheckName       = "kernel-tunables"
+	DiskInventoryCheckName        = "disk-inventory"
+	DiskBenchmarkCheckName        = "disk-benchmark"
 
 	PollInterval = 2 * time.Second
 	PollTimeout  = 3 * time.Minute
@@ -0,0 +1,289 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+	"github.com/iomesh/debugtool/pkg/config"
+	"github.com/iomesh/debugtool/pkg/constant"
+	"github.com/iomesh/debugtool/pkg/disk/inventory"
+	"github.com/iomesh/debugtool/pkg/fixture"
+)
+
+// scratchFileName is the file benchmarked in BenchmarkDir when no device is
+// given.
+const scratchFileName = "iomesh-fio-benchmark"
+
+// SelectDevices returns the devices of BenchmarkDevices to benchmark on a node,
+// those without a node prefix and those prefixed with the node name.
+func SelectDevices(node string, specs []string) []string {
+	devices := []string{}
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if i := strings.Index(spec, ":"); i >= 0 {
+			if spec[:i] != node {
+				continue
+			}
+			spec = spec[i+1:]
+		}
+		if spec != "" {
+			devices = append(devices, spec)
+		}
+	}
+	return devices
+}
+
+type BenchmarkChecker struct {
+	checker.Checker
+}
+
+func NewBenchmarkChecker() *BenchmarkChecker {
+	return &BenchmarkChecker{
+		Checker: checker.Newchecker("BenchmarkChecker"),
+	}
+}
+
+func init() {
+	checker.Register(NewBenchmarkChecker())
+}
+
+func (bc BenchmarkChecker) Name() string {
+	return constant.DiskBenchmarkCheckName
+}
+
+func (bc BenchmarkChecker) Category() checker.Category {
+	return checker.CategoryDisk
+}
+
+func (bc BenchmarkChecker) Dependencies() []string {
+	return nil
+}
+
+func (bc BenchmarkChecker) Description() string {
+	return "Measuring disk performance with fio"
+}
+
+func (bc BenchmarkChecker) Run(ctx context.Context) checker.Result {
+	result := checker.Result{
+		Status:      checker.StatusPass,
+		Remediation: "Use faster disks for the IOMesh cache and data tiers, or check the disk controller cache and firmware settings",
+	}
+	cfg := config.Get()
+	if !cfg.DiskBenchmark {
+		result.Status = checker.StatusSkip
+		result.Message = "Disk benchmark not enabled, enable it with --disk-benchmark"
+		return result
+	}
+
+	pods, err := fixture.GetInstance().NodeCheckerPods(ctx)
+	if err != nil {
+		result.Failf("%v", err)
+		return result
+	}
+
+	// the nodes are benchmarked concurrently, the devices of a node one
+	// after the other so they don't compete for the same controller
+	subResults := make([][]checker.SubResult, len(pods))
+	var wg sync.WaitGroup
+	for i := range pods {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			subResults[i] = bc.benchmarkNode(pods[i], cfg)
+		}(i)
+	}
+	wg.Wait()
+
+	benchmarked := 0
+	for _, srs := range subResults {
+		for _, sr := range srs {
+			if len(sr.Measurements) > 0 {
+				benchmarked++
+			}
+			result.AddSubResult(sr)
+		}
+	}
+	if failed := result.Count(checker.StatusFail); failed > 0 {
+		result.Failf("%d of %d disks don't meet the minimum performance", failed, len(result.SubResults))
+		return result
+	}
+	result.Message = fmt.Sprintf("%d disks meet the minimum performance", benchmarked)
+	return result
+}
+
+// benchmarkNode runs every profile against the selected devices of a node, or
+// its scratch file.
+func (bc BenchmarkChecker) benchmarkNode(pod corev1.Pod, cfg *config.Config) []checker.SubResult {
+	if len(cfg.BenchmarkDevices) == 0 {
+		return []checker.SubResult{bc.benchmarkScratchFile(pod, cfg)}
+	}
+	node := pod.Spec.NodeName
+	devices := SelectDevices(node, cfg.BenchmarkDevices)
+	if len(devices) == 0 {
+		return nil
+	}
+
+	// the read profiles open the devices read only, the write profiles
+	// overwrite them and only run on disks without data once the user
+	// allowed it with --benchmark-destructive
+	disks := map[string]inventory.Disk{}
+	devicesInventory, err := inventory.ReadInventory(bc.Checker, pod.Name)
+	if err != nil {
+		return []checker.SubResult{{
+			Node:    node,
+			Status:  checker.StatusFail,
+			Message: err.Error(),
+		}}
+	}
+	for _, disk := range devicesInventory.Disks() {
+		disks[disk.Path] = disk
+	}
+
+	subResults := []checker.SubResult{}
+	for _, device := range devices {
+		disk, ok := disks[device]
+		switch {
+		case !ok:
+			subResults = append(subResults, checker.SubResult{
+				Node:    node,
+				Device:  device,
+				Status:  checker.StatusFail,
+				Message: "Not benchmarked, no such disk",
+			})
+		case cfg.BenchmarkDestructive && !disk.Eligible:
+			subResults = append(subResults, checker.SubResult{
+				Node:    node,
+				Device:  device,
+				Status:  checker.StatusFail,
+				Message: "Not benchmarked, the disk is in use: " + strings.Join(disk.Reasons, "; "),
+			})
+		default:
+			sr := bc.benchmark(pod, device, device, "", cfg.BenchmarkDestructive, cfg)
+			if sr.Message == "" {
+				sr.Message = disk.String()
+			} else {
+				sr.Message = disk.String() + ": " + sr.Message
+			}
+			subResults = append(subResults, sr)
+		}
+	}
+	return subResults
+}
+
+// benchmarkScratchFile runs every profile against a file in BenchmarkDir of
+// the host, and removes it.
+func (bc BenchmarkChecker) benchmarkScratchFile(pod corev1.Pod, cfg *config.Config) checker.SubResult {
+	hostPath := path.Join(cfg.BenchmarkDir, scratchFileName)
+	filename := path.Join(constant.HostRootPath, hostPath)
+	defer func() {
+		if _, err := bc.RunCmdInPod(pod.Name, constant.DebugNamespace, "rm -f "+filename); err != nil {
+			bc.Log.Error(err, "Remove benchmark scratch file fail", "pod", pod.Name)
+		}
+	}()
+	return bc.benchmark(pod, hostPath, filename, cfg.BenchmarkFileSize, true, cfg)
+}
+
+// benchmark runs the profiles one after the other against filename and
+// compares the results with the minimums. The write profiles only run when
+// writes is set.
+func (bc BenchmarkChecker) benchmark(pod corev1.Pod, device, filename, size string, writes bool, cfg *config.Config) checker.SubResult {
+	sr := checker.SubResult{
+		Node:   pod.Spec.NodeName,
+		Device: device,
+		Status: checker.StatusPass,
+	}
+	fioResults := []FioResult{}
+	for _, profile := range Profiles {
+		if profile.Writes() && !writes {
+			continue
+		}
+		output, err := bc.RunCmdInPod(pod.Name, constant.DebugNamespace, profile.Command(filename, size, cfg.BenchmarkRuntimeSeconds))
+		if err != nil {
+			sr.Status = checker.StatusFail
+			sr.Message = fmt.Sprintf("Run fio profile %s: %v", profile.Name, err)
+			return sr
+		}
+		fioResult, err := ParseFio(profile.Name, output)
+		if err != nil {
+			sr.Status = checker.StatusFail
+			sr.Message = err.Error()
+			return sr
+		}
+		fioResults = append(fioResults, fioResult)
+	}
+	sr.Details = fioResults
+
+	measurements, problems := Evaluate(fioResults, cfg)
+	sr.Measurements = measurements
+	if len(problems) > 0 {
+		sr.Status = checker.StatusFail
+		sr.Message = strings.Join(problems, "; ")
+	} else if !writes {
+		sr.Message = "Write profiles skipped, the device is only read"
+	}
+	return sr
+}
+
+// Evaluate turns the results of the profiles that ran into measurements and
+// lists those beyond the configured limits.
+func Evaluate(results []FioResult, cfg *config.Config) ([]checker.Measurement, []string) {
+	measurements := []checker.Measurement{}
+	problems := []string{}
+	for _, result := range results {
+		switch result.Profile {
+		case "4k-randread":
+			iops := result.Read.IOPS
+			measurements = append(measurements, checker.Measurement{Name: "rand read", Value: iops, Unit: "IOPS"})
+			if iops < cfg.MinRandReadIOPS {
+				problems = append(problems, fmt.Sprintf("4k random read %.0f IOPS below %.0f", iops, cfg.MinRandReadIOPS))
+			}
+		case "4k-randwrite":
+			iops := result.Write.IOPS
+			measurements = append(measurements, checker.Measurement{Name: "rand write", Value: iops, Unit: "IOPS"})
+			if iops < cfg.MinRandWriteIOPS {
+				problems = append(problems, fmt.Sprintf("4k random write %.0f IOPS below %.0f", iops, cfg.MinRandWriteIOPS))
+			}
+		case "1m-seqread":
+			mbps := result.Read.BandwidthMBps
+			measurements = append(measurements, checker.Measurement{Name: "seq read", Value: mbps, Unit: "MB/s"})
+			if mbps < cfg.MinSeqReadMBps {
+				problems = append(problems, fmt.Sprintf("1M sequential read %.0fMB/s below %.0fMB/s", mbps, cfg.MinSeqReadMBps))
+			}
+		case "1m-seqwrite":
+			mbps := result.Write.BandwidthMBps
+			measurements = append(measurements, checker.Measurement{Name: "seq write", Value: mbps, Unit: "MB/s"})
+			if mbps < cfg.MinSeqWriteMBps {
+				problems = append(problems, fmt.Sprintf("1M sequential write %.0fMB/s below %.0fMB/s", mbps, cfg.MinSeqWriteMBps))
+			}
+		case "4k-syncwrite":
+			latency := result.Write
+			measurements = append(measurements,
+				checker.Measurement{Name: "sync write latency", Value: latency.LatencyMS, Unit: "ms"},
+				checker.Measurement{Name: "sync write p99", Value: latency.LatencyP99MS, Unit: "ms"})
+			if latency.LatencyMS > cfg.MaxSyncWriteLatencyMS {
+				problems = append(problems, fmt.Sprintf("sync write latency %.2fms above %.2fms", latency.LatencyMS, cfg.MaxSyncWriteLatencyMS))
+			}
+		}
+	}
+	return measurements, problems
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package benchmark
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Profile is a fio job run against every benchmarked device.
+type Profile struct {
+	Name      string
+	RW        string
+	BlockSize string
+	IODepth   int
+	// Sync opens the target with O_SYNC, so every write reaches stable
+	// storage before it completes.
+	Sync bool
+}
+
+// Profiles are the fio jobs of the benchmark. The write profiles only run on
+// the scratch file, or on devices when the user allowed it.
+var Profiles = []Profile{
+	{Name: "4k-randread", RW: "randread", BlockSize: "4k", IODepth: 32},
+	{Name: "4k-randwrite", RW: "randwrite", BlockSize: "4k", IODepth: 32},
+	{Name: "1m-seqread", RW: "read", BlockSize: "1m", IODepth: 8},
+	{Name: "1m-seqwrite", RW: "write", BlockSize: "1m", IODepth: 8},
+	{Name: "4k-syncwrite", RW: "write", BlockSize: "4k", IODepth: 1, Sync: true},
+}
+
+// Writes tells whether the profile writes to its target.
+func (p Profile) Writes() bool {
+	return strings.Contains(p.RW, "write")
+}
+
+// Command returns the fio command running the profile against filename for
+// runtime seconds. size limits the extent of a scratch file, it is empty for
+// devices. The read profiles run against devices with --readonly, so fio
+// rejects any write, and fio refuses to write to mounted devices. A scratch
+// file is read without it, fio must lay the file out before reading it.
+func (p Profile) Command(filename, size string, runtime int) string {
+	args := []string{
+		"fio",
+		"--name=" + p.Name,
+		"--filename=" + filename,
+		"--rw=" + p.RW,
+		"--bs=" + p.BlockSize,
+		fmt.Sprintf("--iodepth=%d", p.IODepth),
+		"--ioengine=libaio",
+		"--direct=1",
+		"--time_based",
+		fmt.Sprintf("--runtime=%d", runtime),
+		"--group_reporting",
+		"--output-format=json",
+	}
+	if size != "" {
+		args = append(args, "--size="+size)
+	}
+	if !p.Writes() && size == "" {
+		args = append(args, "--readonly")
+	}
+	if p.Sync {
+		args = append(args, "--sync=1")
+	}
+	return strings.Join(args, " ")
+}
+
+// FioStats are the results of one direction of a fio job.
+type FioStats struct {
+	IOPS float64 `json:"iops"`
+	// BandwidthMBps is the throughput in MB/s.
+	BandwidthMBps float64 `json:"bandwidthMBps"`
+	// LatencyMS and LatencyP99MS are the average and 99th percentile
+	// completion latency in milliseconds.
+	LatencyMS    float64 `json:"latencyMS"`
+	LatencyP99MS float64 `json:"latencyP99MS"`
+}
+
+// FioResult is the outcome of a profile.
+type FioResult struct {
+	Profile string   `json:"profile"`
+	Read    FioStats `json:"read"`
+	Write   FioStats `json:"write"`
+}
+
+type fioLatency struct {
+	Mean       float64            `json:"mean"`
+	Percentile map[string]float64 `json:"percentile"`
+}
+
+type fioDirection struct {
+	IOPS float64 `json:"iops"`
+	// BW is in KiB/s
+	BW float64 `json:"bw"`
+	// ClatNS is reported by fio 3, Clat in microseconds by older releases
+	ClatNS fioLatency `json:"clat_ns"`
+	Clat   fioLatency `json:"clat"`
+}
+
+type fioOutput struct {
+	Jobs []struct {
+		Error int          `json:"error"`
+		Read  fioDirection `json:"read"`
+		Write fioDirection `json:"write"`
+	} `json:"jobs"`
+}
+
+func (d fioDirection) stats() FioStats {
+	stats := FioStats{
+		IOPS:          d.IOPS,
+		BandwidthMBps: d.BW * 1024 / 1e6,
+	}
+	latency, toMS := d.ClatNS, 1e-6
+	if latency.Mean == 0 {
+		latency, toMS = d.Clat, 1e-3
+	}
+	stats.LatencyMS = latency.Mean * toMS
+	stats.LatencyP99MS = latency.Percentile["99.000000"] * toMS
+	return stats
+}
+
+// ParseFio parses the JSON output of a fio job run with group reporting. fio
+// may print warnings before the JSON document.
+func ParseFio(profile, output string) (FioResult, error) {
+	start := strings.Index(output, "{")
+	if start < 0 {
+		return FioResult{}, fmt.Errorf("No fio output in %q", output)
+	}
+	fio := fioOutput{}
+	if err := json.Unmarshal([]byte(output[start:]), &fio); err != nil {
+		return FioResult{}, fmt.Errorf("Parse fio output: %v", err)
+	}
+	if len(fio.Jobs) == 0 {
+		return FioResult{}, fmt.Errorf("No job in fio output")
+	}
+	job := fio.Jobs[0]
+	if job.Error != 0 {
+		return FioResult{}, fmt.Errorf("fio job %s failed with error %d", profile, job.Error)
+	}
+	return FioResult{
+		Profile: profile,
+		Read:    job.Read.stats(),
+		Write:   job.Write.stats(),
+	}, nil
+}
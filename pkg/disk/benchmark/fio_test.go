@@ -0,0 +1,156 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package benchmark
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/iomesh/debugtool/pkg/config"
+)
+
+func TestParseFio(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    FioResult
+		wantErr string
+	}{
+		{
+			name:    "empty output",
+			output:  "",
+			wantErr: "No fio output",
+		},
+		{
+			name:    "truncated json",
+			output:  `{"jobs": [{"read": {"iops": 10`,
+			wantErr: "Parse fio output",
+		},
+		{
+			name:    "no job",
+			output:  `{"fio version": "fio-3.28", "jobs": []}`,
+			wantErr: "No job",
+		},
+		{
+			name:    "job error",
+			output:  `{"jobs": [{"error": 5, "read": {}, "write": {}}]}`,
+			wantErr: "failed with error 5",
+		},
+		{
+			name: "fio 3 with warnings",
+			output: `fio: this platform does not support process shared mutexes
+{"fio version": "fio-3.28", "jobs": [{"jobname": "4k-randread", "error": 0,
+ "read": {"iops": 12000.5, "bw": 48000, "clat_ns": {"mean": 250000, "percentile": {"99.000000": 900000}}},
+ "write": {"iops": 0, "bw": 0, "clat_ns": {"mean": 0}}}]}`,
+			want: FioResult{
+				Profile: "4k-randread",
+				Read:    FioStats{IOPS: 12000.5, BandwidthMBps: 49.152, LatencyMS: 0.25, LatencyP99MS: 0.9},
+			},
+		},
+		{
+			name:   "fio 2 latency in microseconds",
+			output: `{"jobs": [{"error": 0, "read": {}, "write": {"iops": 300, "bw": 1200, "clat": {"mean": 3000, "percentile": {"99.000000": 8000}}}}]}`,
+			want: FioResult{
+				Profile: "4k-syncwrite",
+				Write:   FioStats{IOPS: 300, BandwidthMBps: 1.2288, LatencyMS: 3, LatencyP99MS: 8},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile := tt.want.Profile
+			if profile == "" {
+				profile = "4k-randread"
+			}
+			got, err := ParseFio(profile, tt.output)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseFio() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseFio() error = %v", err)
+			}
+			if !approxEqual(got.Read, tt.want.Read) || !approxEqual(got.Write, tt.want.Write) || got.Profile != tt.want.Profile {
+				t.Errorf("ParseFio() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func approxEqual(a, b FioStats) bool {
+	near := func(x, y float64) bool {
+		return x-y < 1e-9 && y-x < 1e-9
+	}
+	return near(a.IOPS, b.IOPS) && near(a.BandwidthMBps, b.BandwidthMBps) &&
+		near(a.LatencyMS, b.LatencyMS) && near(a.LatencyP99MS, b.LatencyP99MS)
+}
+
+func TestProfileCommand(t *testing.T) {
+	for _, profile := range Profiles {
+		cmd := profile.Command("/dev/sdb", "", 10)
+		if readonly := strings.Contains(cmd, "--readonly"); readonly == profile.Writes() {
+			t.Errorf("profile %s: --readonly set %v on %q", profile.Name, readonly, cmd)
+		}
+		if strings.Contains(cmd, "--size") {
+			t.Errorf("profile %s: size set for a device: %q", profile.Name, cmd)
+		}
+	}
+	// fio can't lay out a missing scratch file in read-only mode
+	if cmd := Profiles[0].Command("/host/var/tmp/f", "2G", 10); !strings.Contains(cmd, "--size=2G") || strings.Contains(cmd, "--readonly") {
+		t.Errorf("scratch file command %q has no size or is read-only", cmd)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	cfg := config.Default()
+	reads := []FioResult{
+		{Profile: "4k-randread", Read: FioStats{IOPS: cfg.MinRandReadIOPS - 1}},
+		{Profile: "1m-seqread", Read: FioStats{BandwidthMBps: cfg.MinSeqReadMBps + 1}},
+	}
+	measurements, problems := Evaluate(reads, cfg)
+	if len(measurements) != 2 || len(problems) != 1 || !strings.Contains(problems[0], "4k random read") {
+		t.Errorf("Evaluate(reads) = %v, %v", measurements, problems)
+	}
+
+	measurements, problems = Evaluate(nil, cfg)
+	if len(measurements) != 0 || len(problems) != 0 {
+		t.Errorf("Evaluate(nil) = %v, %v", measurements, problems)
+	}
+
+	sync := []FioResult{{Profile: "4k-syncwrite", Write: FioStats{LatencyMS: cfg.MaxSyncWriteLatencyMS * 2}}}
+	if _, problems := Evaluate(sync, cfg); len(problems) != 1 {
+		t.Errorf("Evaluate(sync) problems = %v", problems)
+	}
+}
+
+func TestSelectDevices(t *testing.T) {
+	specs := []string{"/dev/sdb", "node1:/dev/nvme0n1", "node2:/dev/sdc", " ", "node1:"}
+	tests := map[string][]string{
+		"node1": {"/dev/sdb", "/dev/nvme0n1"},
+		"node2": {"/dev/sdb", "/dev/sdc"},
+		"node3": {"/dev/sdb"},
+	}
+	for node, want := range tests {
+		if got := SelectDevices(node, specs); !reflect.DeepEqual(got, want) {
+			t.Errorf("SelectDevices(%s) = %v, want %v", node, got, want)
+		}
+	}
+	if got := SelectDevices("node1", nil); len(got) != 0 {
+		t.Errorf("SelectDevices(node1, nil) = %v, want none", got)
+	}
+}
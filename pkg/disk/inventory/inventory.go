@@ -0,0 +1,133 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+	"github.com/iomesh/debugtool/pkg/constant"
+	"github.com/iomesh/debugtool/pkg/fixture"
+)
+
+// ReadInventory lists the block devices of the node of a node checker pod.
+func ReadInventory(c checker.Checker, podName string) (Inventory, error) {
+	output, err := c.RunCmdInPod(podName, constant.DebugNamespace, readBlockDevicesCmd)
+	if err != nil {
+		return Inventory{}, fmt.Errorf("List block devices in pod %s: %v", podName, err)
+	}
+	return ParseInventory(output), nil
+}
+
+type InventoryChecker struct {
+	checker.Checker
+}
+
+func NewInventoryChecker() *InventoryChecker {
+	return &InventoryChecker{
+		Checker: checker.Newchecker("InventoryChecker"),
+	}
+}
+
+func init() {
+	checker.Register(NewInventoryChecker())
+}
+
+func (ic InventoryChecker) Name() string {
+	return constant.DiskInventoryCheckName
+}
+
+func (ic InventoryChecker) Category() checker.Category {
+	return checker.CategoryDisk
+}
+
+func (ic InventoryChecker) Dependencies() []string {
+	return nil
+}
+
+func (ic InventoryChecker) Description() string {
+	return "Checking block devices eligible for IOMesh"
+}
+
+func (ic InventoryChecker) Run(ctx context.Context) checker.Result {
+	result := checker.Result{
+		Status:      checker.StatusPass,
+		Remediation: "Attach empty disks to the storage nodes, or wipe the disks meant for IOMesh, for example with: wipefs -a /dev/sdX",
+	}
+
+	pods, err := fixture.GetInstance().NodeCheckerPods(ctx)
+	if err != nil {
+		result.Failf("%v", err)
+		return result
+	}
+
+	eligible, unreadable := 0, 0
+	nodesWithout := []string{}
+	for _, pod := range pods {
+		node := pod.Spec.NodeName
+		inventory, err := ReadInventory(ic.Checker, pod.Name)
+		if err != nil {
+			unreadable++
+			result.AddSubResult(checker.SubResult{
+				Node:    node,
+				Status:  checker.StatusFail,
+				Message: err.Error(),
+			})
+			continue
+		}
+
+		nodeEligible := 0
+		for _, disk := range inventory.Disks() {
+			sr := checker.SubResult{
+				Node:    node,
+				Device:  disk.Path,
+				Status:  checker.StatusPass,
+				Message: disk.String(),
+				Measurements: []checker.Measurement{
+					{Name: "size", Value: float64(disk.SizeBytes) / (1 << 30), Unit: "GiB"},
+				},
+				Details: disk,
+			}
+			// disks in use, such as the root disk, are expected on
+			// every node and only listed with the reason they can't be
+			// used, the status of the check comes from the eligible count
+			if disk.Eligible {
+				nodeEligible++
+				sr.Message = "Eligible, " + sr.Message
+			} else {
+				sr.Message = "Not eligible, " + sr.Message + ": " + strings.Join(disk.Reasons, "; ")
+			}
+			result.AddSubResult(sr)
+		}
+		if nodeEligible == 0 {
+			nodesWithout = append(nodesWithout, node)
+		}
+		eligible += nodeEligible
+	}
+
+	switch {
+	case unreadable > 0:
+		result.Failf("Can't list the block devices of %d nodes", unreadable)
+	case eligible == 0:
+		result.Failf("No eligible disk on any of %d nodes", len(pods))
+	case len(nodesWithout) > 0:
+		result.Warnf("%d eligible disks, none on nodes %v", eligible, strings.Join(nodesWithout, ", "))
+	default:
+		result.Message = fmt.Sprintf("%d eligible disks on %d nodes", eligible, len(pods))
+	}
+	return result
+}
@@ -0,0 +1,266 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package inventory
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// readBlockDevicesCmd lists the block devices of the host in lsblk pairs, a
+// device and its children once per parent, leaving out RAM disks, loop
+// devices and CD-ROMs. The filesystem or partition table signature of every
+// disk is probed directly, bypassing the udev database, and the LVM physical
+// volumes are listed in the same KEY="value" format.
+const readBlockDevicesCmd = `H="nsenter -t 1 -m --"
+$H lsblk -P -b -n -e 1,7,11 -o KNAME,PKNAME,TYPE,SIZE,ROTA,TRAN,MODEL,SERIAL,FSTYPE,MOUNTPOINT
+for d in $($H lsblk -d -n -r -e 1,7,11 -o KNAME,TYPE | awk '$2 == "disk" {print $1}'); do
+  echo "KNAME=\"$d\" SIGNATURE=\"$($H blkid -p -o value -s TYPE -s PTTYPE /dev/$d 2>/dev/null | tr '\n' ' ')\""
+done
+for pv in $($H pvs --noheadings -o pv_name 2>/dev/null); do
+  echo "KNAME=\"$(basename $($H readlink -f $pv))\" PV=\"1\""
+done
+true`
+
+// BlockDevice is a line of lsblk.
+type BlockDevice struct {
+	Name       string
+	Parent     string
+	Type       string
+	Size       int64
+	Rotational bool
+	Transport  string
+	Model      string
+	Serial     string
+	FSType     string
+	MountPoint string
+}
+
+var pairRegexp = regexp.MustCompile(`([A-Z:_-]+)="([^"]*)"`)
+
+var escapeRegexp = regexp.MustCompile(`\\x[0-9a-fA-F]{2}`)
+
+// parsePairs parses a line of KEY="value" pairs, lsblk escapes quotes and
+// other unsafe characters in values as \xNN.
+func parsePairs(line string) map[string]string {
+	pairs := map[string]string{}
+	for _, match := range pairRegexp.FindAllStringSubmatch(line, -1) {
+		pairs[match[1]] = strings.TrimSpace(escapeRegexp.ReplaceAllStringFunc(match[2], func(escape string) string {
+			b, _ := strconv.ParseUint(escape[2:], 16, 8)
+			return string(rune(b))
+		}))
+	}
+	return pairs
+}
+
+// Inventory is the block devices of a node.
+type Inventory struct {
+	Devices []BlockDevice
+	// Signatures are the filesystem or partition table types found on
+	// each disk.
+	Signatures map[string]string
+	// PhysicalVolumes are the devices used by LVM.
+	PhysicalVolumes map[string]bool
+}
+
+// ParseInventory parses the output of readBlockDevicesCmd.
+func ParseInventory(output string) Inventory {
+	inventory := Inventory{
+		Signatures:      map[string]string{},
+		PhysicalVolumes: map[string]bool{},
+	}
+	for _, line := range strings.Split(output, "\n") {
+		pairs := parsePairs(line)
+		name := pairs["KNAME"]
+		if name == "" {
+			continue
+		}
+		if signature, ok := pairs["SIGNATURE"]; ok {
+			if signature != "" {
+				inventory.Signatures[name] = signature
+			}
+			continue
+		}
+		if _, ok := pairs["PV"]; ok {
+			inventory.PhysicalVolumes[name] = true
+			continue
+		}
+		size, _ := strconv.ParseInt(pairs["SIZE"], 10, 64)
+		inventory.Devices = append(inventory.Devices, BlockDevice{
+			Name:       name,
+			Parent:     pairs["PKNAME"],
+			Type:       pairs["TYPE"],
+			Size:       size,
+			Rotational: pairs["ROTA"] == "1",
+			Transport:  pairs["TRAN"],
+			Model:      pairs["MODEL"],
+			Serial:     pairs["SERIAL"],
+			FSType:     pairs["FSTYPE"],
+			MountPoint: pairs["MOUNTPOINT"],
+		})
+	}
+	return inventory
+}
+
+// Disk is a whole disk of a node and whether IOMesh can use it.
+type Disk struct {
+	Path string `json:"path"`
+	// Class is NVMe, SSD or HDD.
+	Class      string `json:"class"`
+	Rotational bool   `json:"rotational"`
+	SizeBytes  int64  `json:"sizeBytes"`
+	Transport  string `json:"transport,omitempty"`
+	Model      string `json:"model,omitempty"`
+	Serial     string `json:"serial,omitempty"`
+	Eligible   bool   `json:"eligible"`
+	// Reasons tell why the disk is not eligible.
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+func (d Disk) String() string {
+	facts := []string{d.Class, FormatSize(d.SizeBytes)}
+	if d.Model != "" {
+		facts = append(facts, d.Model)
+	}
+	if d.Serial != "" {
+		facts = append(facts, "serial "+d.Serial)
+	}
+	return strings.Join(facts, ", ")
+}
+
+// FormatSize formats a size in bytes in GiB, or TiB from 1TiB on.
+func FormatSize(bytes int64) string {
+	if bytes >= 1<<40 {
+		return fmt.Sprintf("%.1fTiB", float64(bytes)/(1<<40))
+	}
+	return fmt.Sprintf("%.1fGiB", float64(bytes)/(1<<30))
+}
+
+// Disks classifies the whole disks of an inventory. A disk is eligible when it
+// has no partitions or other children, carries no filesystem or partition
+// table signature, is not mounted, is not an LVM physical volume and does not
+// hold the root filesystem.
+func (inv Inventory) Disks() []Disk {
+	children := map[string][]BlockDevice{}
+	for _, device := range inv.Devices {
+		if device.Parent != "" {
+			children[device.Parent] = append(children[device.Parent], device)
+		}
+	}
+
+	disks := []Disk{}
+	seen := map[string]bool{}
+	for _, device := range inv.Devices {
+		if device.Type != "disk" || device.Parent != "" || device.Size == 0 || seen[device.Name] {
+			continue
+		}
+		seen[device.Name] = true
+		disk := Disk{
+			Path:       "/dev/" + device.Name,
+			Rotational: device.Rotational,
+			SizeBytes:  device.Size,
+			Transport:  device.Transport,
+			Model:      device.Model,
+			Serial:     device.Serial,
+		}
+		switch {
+		case device.Transport == "nvme" || strings.HasPrefix(device.Name, "nvme"):
+			disk.Class = "NVMe"
+		case !device.Rotational:
+			disk.Class = "SSD"
+		default:
+			disk.Class = "HDD"
+		}
+
+		descendants := inv.descendants(device.Name, children)
+		mounts := []string{}
+		root := false
+		for _, d := range append([]BlockDevice{device}, descendants...) {
+			if d.MountPoint == "" {
+				continue
+			}
+			if d.MountPoint == "/" {
+				root = true
+			}
+			mounts = append(mounts, d.MountPoint)
+		}
+		if root {
+			disk.Reasons = append(disk.Reasons, "root disk")
+		}
+		if len(mounts) > 0 {
+			disk.Reasons = append(disk.Reasons, "mounted at "+strings.Join(dedup(mounts), ", "))
+		}
+		partitions, holders := 0, []string{}
+		for _, child := range children[device.Name] {
+			if child.Type == "part" {
+				partitions++
+			} else {
+				holders = append(holders, child.Type+" "+child.Name)
+			}
+		}
+		if partitions > 0 {
+			disk.Reasons = append(disk.Reasons, fmt.Sprintf("%d partitions", partitions))
+		}
+		if len(holders) > 0 {
+			disk.Reasons = append(disk.Reasons, "used by "+strings.Join(dedup(holders), ", "))
+		}
+		pv := inv.PhysicalVolumes[device.Name] || device.FSType == "LVM2_member"
+		for _, child := range children[device.Name] {
+			pv = pv || inv.PhysicalVolumes[child.Name] || child.FSType == "LVM2_member"
+		}
+		if pv {
+			disk.Reasons = append(disk.Reasons, "LVM physical volume")
+		}
+		signature := inv.Signatures[device.Name]
+		if signature == "" {
+			signature = device.FSType
+		}
+		if signature = strings.TrimSpace(signature); signature != "" && signature != "LVM2_member" {
+			disk.Reasons = append(disk.Reasons, "signature "+signature)
+		}
+		disk.Eligible = len(disk.Reasons) == 0
+		disks = append(disks, disk)
+	}
+	sort.Slice(disks, func(i, j int) bool {
+		return disks[i].Path < disks[j].Path
+	})
+	return disks
+}
+
+// descendants returns the children of a device and their own children, such
+// as the partitions of a disk and the logical volumes on them.
+func (inv Inventory) descendants(name string, children map[string][]BlockDevice) []BlockDevice {
+	descendants := []BlockDevice{}
+	for _, child := range children[name] {
+		descendants = append(descendants, child)
+		descendants = append(descendants, inv.descendants(child.Name, children)...)
+	}
+	return descendants
+}
+
+func dedup(values []string) []string {
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package inventory
+
+import (
+	"reflect"
+	"testing"
+)
+
+// hostOutput is the output of readBlockDevicesCmd on a node with an LVM root
+// disk, blank disks, a formatted disk, a whole-disk physical volume and a
+// multipath disk seen through two paths.
+const hostOutput = `KNAME="sda" PKNAME="" TYPE="disk" SIZE="480103981056" ROTA="0" TRAN="sata" MODEL="INTEL SSDSC2KB48" SERIAL="BTYF1234" FSTYPE="" MOUNTPOINT=""
+KNAME="sda1" PKNAME="sda" TYPE="part" SIZE="1073741824" ROTA="0" TRAN="" MODEL="" SERIAL="" FSTYPE="xfs" MOUNTPOINT="/boot"
+KNAME="sda2" PKNAME="sda" TYPE="part" SIZE="479029190656" ROTA="0" TRAN="" MODEL="" SERIAL="" FSTYPE="LVM2_member" MOUNTPOINT=""
+KNAME="dm-0" PKNAME="sda2" TYPE="lvm" SIZE="470439256064" ROTA="0" TRAN="" MODEL="" SERIAL="" FSTYPE="xfs" MOUNTPOINT="/"
+KNAME="dm-1" PKNAME="sda2" TYPE="lvm" SIZE="8589934592" ROTA="0" TRAN="" MODEL="" SERIAL="" FSTYPE="swap" MOUNTPOINT="[SWAP]"
+KNAME="sdb" PKNAME="" TYPE="disk" SIZE="1920383410176" ROTA="0" TRAN="sas" MODEL="Samsung\x20SSD\x20PM1643" SERIAL="S41J\x22NA0" FSTYPE="" MOUNTPOINT=""
+KNAME="sdc" PKNAME="" TYPE="disk" SIZE="8001563222016" ROTA="1" TRAN="sas" MODEL="ST8000NM0055" SERIAL="ZA1ABCDE" FSTYPE="xfs" MOUNTPOINT=""
+KNAME="sdd" PKNAME="" TYPE="disk" SIZE="4000787030016" ROTA="1" TRAN="sas" MODEL="ST4000NM0035" SERIAL="ZC1ABCDE" FSTYPE="LVM2_member" MOUNTPOINT=""
+KNAME="sde" PKNAME="" TYPE="disk" SIZE="1099511627776" ROTA="0" TRAN="iscsi" MODEL="LUN" SERIAL="6001405abc" FSTYPE="mpath_member" MOUNTPOINT=""
+KNAME="dm-2" PKNAME="sde" TYPE="mpath" SIZE="1099511627776" ROTA="0" TRAN="" MODEL="" SERIAL="" FSTYPE="" MOUNTPOINT=""
+KNAME="sdf" PKNAME="" TYPE="disk" SIZE="1099511627776" ROTA="0" TRAN="iscsi" MODEL="LUN" SERIAL="6001405abc" FSTYPE="mpath_member" MOUNTPOINT=""
+KNAME="dm-2" PKNAME="sdf" TYPE="mpath" SIZE="1099511627776" ROTA="0" TRAN="" MODEL="" SERIAL="" FSTYPE="" MOUNTPOINT=""
+KNAME="sdg" PKNAME="" TYPE="disk" SIZE="0" ROTA="1" TRAN="usb" MODEL="Card\x20Reader" SERIAL="" FSTYPE="" MOUNTPOINT=""
+KNAME="nvme0n1" PKNAME="" TYPE="disk" SIZE="3840755982336" ROTA="0" TRAN="nvme" MODEL="INTEL SSDPE2KX040T8" SERIAL="PHLJ1234" FSTYPE="" MOUNTPOINT=""
+KNAME="sda" SIGNATURE="gpt "
+KNAME="sdb" SIGNATURE=""
+KNAME="sdc" SIGNATURE="xfs "
+KNAME="sdd" SIGNATURE="LVM2_member "
+KNAME="sde" SIGNATURE="mpath_member "
+KNAME="sdf" SIGNATURE="mpath_member "
+KNAME="nvme0n1" SIGNATURE=""
+KNAME="sda2" PV="1"
+KNAME="sdd" PV="1"
+`
+
+func TestParseInventory(t *testing.T) {
+	inv := ParseInventory(hostOutput)
+	if len(inv.Devices) != 14 {
+		t.Errorf("got %d devices, want 14", len(inv.Devices))
+	}
+	sdb := inv.Devices[5]
+	want := BlockDevice{Name: "sdb", Type: "disk", Size: 1920383410176, Transport: "sas", Model: "Samsung SSD PM1643", Serial: `S41J"NA0`}
+	if sdb != want {
+		t.Errorf("sdb = %+v, want %+v", sdb, want)
+	}
+	wantSignatures := map[string]string{"sda": "gpt", "sdc": "xfs", "sdd": "LVM2_member", "sde": "mpath_member", "sdf": "mpath_member"}
+	if !reflect.DeepEqual(inv.Signatures, wantSignatures) {
+		t.Errorf("signatures = %v, want %v", inv.Signatures, wantSignatures)
+	}
+	wantPVs := map[string]bool{"sda2": true, "sdd": true}
+	if !reflect.DeepEqual(inv.PhysicalVolumes, wantPVs) {
+		t.Errorf("physical volumes = %v, want %v", inv.PhysicalVolumes, wantPVs)
+	}
+
+	empty := ParseInventory("")
+	if len(empty.Devices) != 0 || len(empty.Signatures) != 0 || len(empty.PhysicalVolumes) != 0 {
+		t.Errorf("ParseInventory(\"\") = %+v", empty)
+	}
+	garbage := ParseInventory("lsblk: unknown column: TRAN\nnsenter: cannot open /proc/1/ns/mnt\n")
+	if len(garbage.Devices) != 0 {
+		t.Errorf("ParseInventory(garbage) = %+v", garbage)
+	}
+}
+
+func TestDisks(t *testing.T) {
+	type disk struct {
+		path     string
+		class    string
+		eligible bool
+		reasons  []string
+	}
+	tests := []struct {
+		name   string
+		output string
+		want   []disk
+	}{
+		{
+			name:   "no disk",
+			output: "",
+			want:   []disk{},
+		},
+		{
+			name:   "host",
+			output: hostOutput,
+			want: []disk{
+				{"/dev/nvme0n1", "NVMe", true, nil},
+				{"/dev/sda", "SSD", false, []string{"root disk", "mounted at /boot, /, [SWAP]", "2 partitions", "LVM physical volume", "signature gpt"}},
+				{"/dev/sdb", "SSD", true, nil},
+				{"/dev/sdc", "HDD", false, []string{"signature xfs"}},
+				{"/dev/sdd", "HDD", false, []string{"LVM physical volume"}},
+				{"/dev/sde", "SSD", false, []string{"used by mpath dm-2", "signature mpath_member"}},
+				{"/dev/sdf", "SSD", false, []string{"used by mpath dm-2", "signature mpath_member"}},
+			},
+		},
+		{
+			// without udev, lsblk knows no filesystem, blkid still
+			// finds the partition table
+			name: "signature only found by blkid",
+			output: `KNAME="vdb" PKNAME="" TYPE="disk" SIZE="107374182400" ROTA="1" TRAN="" MODEL="" SERIAL="" FSTYPE="" MOUNTPOINT=""
+KNAME="vdb" SIGNATURE="dos "
+`,
+			want: []disk{{"/dev/vdb", "HDD", false, []string{"signature dos"}}},
+		},
+		{
+			name: "disk listed twice",
+			output: `KNAME="vdc" PKNAME="" TYPE="disk" SIZE="107374182400" ROTA="0" TRAN="" MODEL="" SERIAL="" FSTYPE="" MOUNTPOINT=""
+KNAME="vdc" PKNAME="" TYPE="disk" SIZE="107374182400" ROTA="0" TRAN="" MODEL="" SERIAL="" FSTYPE="" MOUNTPOINT=""
+`,
+			want: []disk{{"/dev/vdc", "SSD", true, nil}},
+		},
+		{
+			name: "mounted whole disk",
+			output: `KNAME="vdd" PKNAME="" TYPE="disk" SIZE="107374182400" ROTA="0" TRAN="" MODEL="" SERIAL="" FSTYPE="ext4" MOUNTPOINT="/var/lib/docker"
+KNAME="vdd" SIGNATURE="ext4 "
+`,
+			want: []disk{{"/dev/vdd", "SSD", false, []string{"mounted at /var/lib/docker", "signature ext4"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []disk{}
+			for _, d := range ParseInventory(tt.output).Disks() {
+				got = append(got, disk{d.Path, d.Class, d.Eligible, d.Reasons})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Disks() =\n%v\nwant\n%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		bytes int64
+		want  string
+	}{
+		{0, "0.0GiB"},
+		{480103981056, "447.1GiB"},
+		{1 << 40, "1.0TiB"},
+		{3840755982336, "3.5TiB"},
+	}
+	for _, tt := range tests {
+		if got := FormatSize(tt.bytes); got != tt.want {
+			t.Errorf("FormatSize(%d) = %q, want %q", tt.bytes, got, tt.want)
+		}
+	}
+}
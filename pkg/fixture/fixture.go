@@ -16,10 +16,8 @@ package fixture
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"net"
-	"os"
+	"sort"
 	"sync"
 
 	"github.com/enescakir/emoji"
@@ -27,8 +25,10 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 
 	"github.com/iomesh/debugtool/pkg/checker"
+	"github.com/iomesh/debugtool/pkg/config"
 	"github.com/iomesh/debugtool/pkg/constant"
 	"github.com/iomesh/debugtool/pkg/kutils"
 )
@@ -57,15 +57,9 @@ func (f Fixture) EnsureBasicDsDeployed() error {
 	f.SpinnerStart()
 
 	// create debug namespace if not exist
-	debugNamespace := &corev1.Namespace{}
-	debugNamespaceLookupKey := types.NamespacedName{
-		Name: constant.DebugNamespace,
-	}
-	if err := f.Client.Get(context.TODO(), debugNamespaceLookupKey, debugNamespace); err != nil {
-		if err := f.Client.Create(context.TODO(), kutils.NewNamespace(constant.DebugNamespace)); err != nil {
-			f.SpinnerStop(emoji.CrossMark)
-			return fmt.Errorf("Create debug namespace: %v", err)
-		}
+	if err := f.ensureNamespace(context.TODO()); err != nil {
+		f.SpinnerStop(emoji.CrossMark)
+		return err
 	}
 
 	// create basic checker daemonset if not exist
@@ -95,10 +89,161 @@ func (f Fixture) EnsureBasicDsDeployed() error {
 	}
 
 	f.SpinnerStop(emoji.CheckMarkButton)
-	fmt.Println("")
+	if checker.Interactive() {
+		fmt.Println("")
+	}
+	return nil
+}
+
+func (f Fixture) ensureNamespace(ctx context.Context) error {
+	debugNamespace := &corev1.Namespace{}
+	debugNamespaceLookupKey := types.NamespacedName{
+		Name: constant.DebugNamespace,
+	}
+	if err := f.Client.Get(ctx, debugNamespaceLookupKey, debugNamespace); err != nil {
+		if err := f.Client.Create(ctx, kutils.NewNamespace(constant.DebugNamespace)); err != nil {
+			return fmt.Errorf("Create debug namespace: %v", err)
+		}
+	}
+	return nil
+}
+
+// EnsureNodeDsDeployed creates the privileged node checker daemonset if it
+// does not exist yet and waits for it to be ready. Its pods share the network
+// and PID namespaces of their node and mount its root filesystem, they are
+// used by every check inspecting the nodes themselves.
+func (f Fixture) EnsureNodeDsDeployed(ctx context.Context) error {
+	if err := f.ensureNamespace(ctx); err != nil {
+		return err
+	}
+
+	ds := &appsv1.DaemonSet{}
+	dsLookupKey := types.NamespacedName{
+		Name:      constant.NodeCheckerDSName,
+		Namespace: constant.DebugNamespace,
+	}
+	if err := f.Client.Get(ctx, dsLookupKey, ds); err != nil {
+		ds = f.NodeCheckerDaemonSet(constant.DebugNamespace, constant.NodeCheckerDSName)
+		if err := f.Client.Create(ctx, ds); err != nil {
+			return fmt.Errorf("Create node checker daemonset: %v", err)
+		}
+	}
+	if err := kutils.WaitDaemonSetReady(f.Client, constant.DebugNamespace, constant.NodeCheckerDSName); err != nil {
+		return fmt.Errorf("Wait node checker daemonset ready %v", err)
+	}
+	return nil
+}
+
+// NodeCheckerPods deploys the node checker daemonset and returns its pods
+// ordered by node.
+func (f Fixture) NodeCheckerPods(ctx context.Context) ([]corev1.Pod, error) {
+	if err := f.EnsureNodeDsDeployed(ctx); err != nil {
+		return nil, err
+	}
+	pods, err := kutils.ListPods(ctx, f.Client, constant.DebugNamespace, constant.NodeCheckerLabel)
+	if err != nil {
+		return nil, fmt.Errorf("List node checker pods: %v", err)
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].Spec.NodeName < pods[j].Spec.NodeName
+	})
+	return pods, nil
+}
+
+// EnsureHostNetworkDsDeployed creates the hostnetwork checker daemonset if it
+// does not exist yet and waits for it to be ready. It is shared by every check
+// measuring the host network and by the data network discovery. A daemonset
+// created before the data network was resolved is updated with it.
+func (f Fixture) EnsureHostNetworkDsDeployed(ctx context.Context) error {
+	if err := f.ensureNamespace(ctx); err != nil {
+		return err
+	}
+
+	desired, err := f.HostNetworkCheckerDaemonSet(constant.DebugNamespace, constant.HostNetworkCheckerDSName)
+	if err != nil {
+		return fmt.Errorf("Create hostnetwork checker daemonset: %v", err)
+	}
+	ds := &appsv1.DaemonSet{}
+	dsLookupKey := types.NamespacedName{
+		Name:      constant.HostNetworkCheckerDSName,
+		Namespace: constant.DebugNamespace,
+	}
+	if err := f.Client.Get(ctx, dsLookupKey, ds); err != nil {
+		if err := f.Client.Create(ctx, desired); err != nil {
+			return fmt.Errorf("Create hostnetwork checker daemonset: %v", err)
+		}
+	} else if dataCIDREnv(ds) != dataCIDREnv(desired) {
+		ds.Spec.Template = desired.Spec.Template
+		if err := f.Client.Update(ctx, ds); err != nil {
+			return fmt.Errorf("Update hostnetwork checker daemonset: %v", err)
+		}
+	}
+	if err := kutils.WaitDaemonSetReady(f.Client, constant.DebugNamespace, constant.HostNetworkCheckerDSName); err != nil {
+		return fmt.Errorf("Wait hostnetwork checker daemonset ready %v", err)
+	}
 	return nil
 }
 
+// EnsureDebugService creates the debug service if it does not exist yet. The
+// service selects the basic checker pods and is exposed as a NodePort, so it
+// can be resolved by name as well as reached through kube-proxy.
+func (f Fixture) EnsureDebugService(ctx context.Context) (*corev1.Service, error) {
+	service := &corev1.Service{}
+	serviceLookupKey := types.NamespacedName{
+		Name:      constant.DebugServiceName,
+		Namespace: constant.DebugNamespace,
+	}
+	if err := f.Client.Get(ctx, serviceLookupKey, service); err == nil {
+		return service, nil
+	}
+
+	service = kutils.NewService(constant.DebugNamespace, constant.DebugServiceName)
+	service.Spec.Type = corev1.ServiceTypeNodePort
+	service.Spec.Selector = map[string]string{
+		"app": constant.BasicCheckerLabel,
+	}
+	service.Spec.Ports = []corev1.ServicePort{
+		{
+			Port:       constant.IperfPort,
+			TargetPort: intstr.FromInt(constant.IperfPort),
+		},
+	}
+	if err := f.Client.Create(ctx, service); err != nil {
+		return nil, fmt.Errorf("Create debug service: %v", err)
+	}
+	return service, nil
+}
+
+// EnsureDebugHeadlessService creates the headless debug service if it does
+// not exist yet. The cluster DNS serves a record for each basic checker pod
+// behind it.
+func (f Fixture) EnsureDebugHeadlessService(ctx context.Context) (*corev1.Service, error) {
+	service := &corev1.Service{}
+	serviceLookupKey := types.NamespacedName{
+		Name:      constant.DebugHeadlessServiceName,
+		Namespace: constant.DebugNamespace,
+	}
+	if err := f.Client.Get(ctx, serviceLookupKey, service); err == nil {
+		return service, nil
+	}
+
+	service = kutils.NewService(constant.DebugNamespace, constant.DebugHeadlessServiceName)
+	service.Spec.ClusterIP = corev1.ClusterIPNone
+	service.Spec.Selector = map[string]string{
+		"app": constant.BasicCheckerLabel,
+	}
+	service.Spec.Ports = []corev1.ServicePort{
+		{
+			Port:       constant.IperfPort,
+			TargetPort: intstr.FromInt(constant.IperfPort),
+		},
+	}
+	if err := f.Client.Create(ctx, service); err != nil {
+		return nil, fmt.Errorf("Create headless debug service: %v", err)
+	}
+	return service, nil
+}
+
 func (f Fixture) Cleanup() error {
 	ns := &corev1.Namespace{}
 	nsLookupKey := types.NamespacedName{
@@ -116,10 +261,8 @@ func (f Fixture) Cleanup() error {
 }
 
 func (f Fixture) BasicCheckerDaemonSet(namespace, name string) (*appsv1.DaemonSet, error) {
-	dataCIRD := os.Getenv("IOMESH_DATA_CIDR")
-	_, _, err := net.ParseCIDR(dataCIRD)
-	if err != nil {
-		return nil, errors.New("IOMESH_DATA_CIDR is not the correct cidr format. example: IOMESH_DATA_CIDR=192.168.1.0/24")
+	if _, err := config.Get().DataCIDRs(); err != nil {
+		return nil, err
 	}
 	ds := kutils.NewDaemonSet(namespace, name)
 	labels := map[string]string{
@@ -145,12 +288,110 @@ func (f Fixture) BasicCheckerDaemonSet(namespace, name string) (*appsv1.DaemonSe
 	return ds, nil
 }
 
+// dataCIDREnv returns the data network a hostnetwork checker daemonset was
+// created with.
+func dataCIDREnv(ds *appsv1.DaemonSet) string {
+	for _, container := range ds.Spec.Template.Spec.Containers {
+		for _, env := range container.Env {
+			if env.Name == "DATA_CIDR" {
+				return env.Value
+			}
+		}
+	}
+	return ""
+}
+
+func (f Fixture) HostNetworkCheckerDaemonSet(namespace, name string) (*appsv1.DaemonSet, error) {
+	// the data network is unknown while it is being discovered, the
+	// daemonset is updated once it is resolved
+	dataCIRD := ""
+	if !config.Get().DataCIDRUnset() {
+		networks, err := config.Get().DataCIDRs()
+		if err != nil {
+			return nil, err
+		}
+		// the image binds its iperf3 server to the primary family, the
+		// checks start servers for the other family themselves
+		dataCIRD = networks[0].String()
+	}
+	ds := kutils.NewDaemonSet(namespace, name)
+	labels := map[string]string{
+		"app": constant.HostNetworkCheckerLabel,
+	}
+	ds.Spec.Selector = &metav1.LabelSelector{
+		MatchLabels: labels,
+	}
+	ds.Spec.Template.ObjectMeta.Labels = labels
+	ds.Spec.Template.Spec.HostNetwork = true
+
+	container := corev1.Container{
+		Name:  constant.HostNetworkCheckerLabel,
+		Image: constant.DebugToolsImage,
+		Env: []corev1.EnvVar{
+			{
+				Name:  "DATA_CIDR",
+				Value: dataCIRD,
+			},
+		},
+	}
+	ds.Spec.Template.Spec.Containers = append(ds.Spec.Template.Spec.Containers, container)
+
+	return ds, nil
+}
+
+func (f Fixture) NodeCheckerDaemonSet(namespace, name string) *appsv1.DaemonSet {
+	ds := kutils.NewDaemonSet(namespace, name)
+	labels := map[string]string{
+		"app": constant.NodeCheckerLabel,
+	}
+	ds.Spec.Selector = &metav1.LabelSelector{
+		MatchLabels: labels,
+	}
+	ds.Spec.Template.ObjectMeta.Labels = labels
+	ds.Spec.Template.Spec.HostNetwork = true
+	ds.Spec.Template.Spec.HostPID = true
+
+	container := corev1.Container{
+		Name:    constant.NodeCheckerLabel,
+		Image:   constant.DebugToolsImage,
+		Command: []string{"sleep", "infinity"},
+		SecurityContext: &corev1.SecurityContext{
+			Privileged: &[]bool{true}[0],
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      "host-root",
+				MountPath: constant.HostRootPath,
+			},
+		},
+	}
+	ds.Spec.Template.Spec.Containers = append(ds.Spec.Template.Spec.Containers, container)
+	ds.Spec.Template.Spec.Volumes = []corev1.Volume{
+		{
+			Name: "host-root",
+			VolumeSource: corev1.VolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{
+					Path: "/",
+				},
+			},
+		},
+	}
+
+	return ds
+}
+
 func (f Fixture) SpinnerStart() {
+	if !checker.Interactive() {
+		return
+	}
 	f.Spinner.Suffix = " Preparing debug environment"
 	f.Spinner.Start()
 }
 
 func (f Fixture) SpinnerStop(emoji emoji.Emoji) {
+	if !checker.Interactive() {
+		return
+	}
 	f.Spinner.FinalMSG = fmt.Sprintf("%v Preparing debug environment\n", emoji)
 	f.Spinner.Stop()
 }
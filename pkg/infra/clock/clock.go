@@ -0,0 +1,300 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package clock
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/rest"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+	"github.com/iomesh/debugtool/pkg/config"
+	"github.com/iomesh/debugtool/pkg/constant"
+	"github.com/iomesh/debugtool/pkg/fixture"
+)
+
+// clockSamples is how many times each clock is read, the sample with the
+// shortest round trip is kept.
+const clockSamples = 5
+
+// Sample is the offset of a clock from the local clock of the debug tool. The
+// local clock cancels out when two offsets are subtracted.
+type Sample struct {
+	// Offset is the remote clock minus the local clock, its error is at
+	// most Uncertainty.
+	Offset      time.Duration
+	Uncertainty time.Duration
+}
+
+// Skew returns the difference between the clocks of two samples and the
+// uncertainty of the difference.
+func (s Sample) Skew(other Sample) (time.Duration, time.Duration) {
+	return s.Offset - other.Offset, s.Uncertainty + other.Uncertainty
+}
+
+// exceeds tells whether a skew is beyond the limit even in the best case of
+// its uncertainty.
+func exceeds(skew, uncertainty time.Duration, limitMS float64) bool {
+	return math.Abs(ms(skew))-ms(uncertainty) > limitMS
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+type ClockChecker struct {
+	checker.Checker
+}
+
+func NewClockChecker() *ClockChecker {
+	return &ClockChecker{
+		Checker: checker.Newchecker("ClockChecker"),
+	}
+}
+
+func init() {
+	checker.Register(NewClockChecker())
+}
+
+func (cc ClockChecker) Name() string {
+	return constant.ClockSkewCheckName
+}
+
+func (cc ClockChecker) Category() checker.Category {
+	return checker.CategoryInfra
+}
+
+func (cc ClockChecker) Dependencies() []string {
+	return nil
+}
+
+func (cc ClockChecker) Description() string {
+	return "Checking node clock skew"
+}
+
+// nodeClock is the clock sample and the time synchronization state of a node.
+type nodeClock struct {
+	Node     string   `json:"node"`
+	Sample   Sample   `json:"-"`
+	TimeSync TimeSync `json:"timeSync"`
+}
+
+func (cc ClockChecker) Run(ctx context.Context) checker.Result {
+	result := checker.Result{
+		Status:      checker.StatusPass,
+		Remediation: "Run chronyd or another NTP client synchronized to the same time source on every node",
+	}
+	maxSkewMS := config.Get().MaxClockSkewMS
+
+	pods, err := fixture.GetInstance().NodeCheckerPods(ctx)
+	if err != nil {
+		result.Failf("%v", err)
+		return result
+	}
+
+	// the nodes are still compared with each other when the API server
+	// clock can't be read, the missing skew to it is reported below
+	apiServer, apiErr := cc.sampleAPIServer(ctx)
+
+	// the nodes are sampled one after the other, concurrent execs would
+	// lengthen the round trips and so the uncertainty
+	clocks := []nodeClock{}
+	unreadable, skewedNodes := 0, 0
+	for _, pod := range pods {
+		sr := checker.SubResult{
+			Node:   pod.Spec.NodeName,
+			Status: checker.StatusPass,
+		}
+		sample, err := cc.sampleNode(pod.Name)
+		if err != nil {
+			unreadable++
+			sr.Status = checker.StatusFail
+			sr.Message = err.Error()
+			result.AddSubResult(sr)
+			continue
+		}
+		output, err := cc.RunCmdInPod(pod.Name, constant.DebugNamespace, readTimeSyncCmd)
+		if err != nil {
+			cc.Log.Error(err, "Read time synchronization state fail", "pod", pod.Name)
+		}
+		clock := nodeClock{
+			Node:     pod.Spec.NodeName,
+			Sample:   sample,
+			TimeSync: ParseTimeSync(output),
+		}
+		clocks = append(clocks, clock)
+		sr.Details = clock
+
+		sr.Message = clock.TimeSync.String()
+		if !clock.TimeSync.Synchronized() {
+			sr.Status = checker.StatusWarn
+		}
+		if apiErr == nil {
+			skew, uncertainty := sample.Skew(apiServer)
+			sr.Measurements = []checker.Measurement{
+				{Name: "skew to api server", Value: ms(skew), Unit: "ms"},
+				{Name: "uncertainty", Value: ms(uncertainty), Unit: "ms"},
+			}
+			sr.Message += fmt.Sprintf(", %+.0fms ±%.0fms from the API server", ms(skew), ms(uncertainty))
+			if exceeds(skew, uncertainty, maxSkewMS) {
+				skewedNodes++
+				sr.Status = checker.StatusFail
+			}
+		}
+		result.AddSubResult(sr)
+	}
+
+	var maxSkew time.Duration
+	skewedPairs := 0
+	for _, clock := range clocks {
+		for _, peer := range clocks {
+			if clock.Node == peer.Node {
+				continue
+			}
+			skew, uncertainty := clock.Sample.Skew(peer.Sample)
+			sr := checker.SubResult{
+				Node:   clock.Node,
+				Peer:   peer.Node,
+				Status: checker.StatusPass,
+				Measurements: []checker.Measurement{
+					{Name: "skew", Value: ms(skew), Unit: "ms"},
+				},
+			}
+			if skew > maxSkew {
+				maxSkew = skew
+			}
+			if exceeds(skew, uncertainty, maxSkewMS) {
+				sr.Status = checker.StatusFail
+				sr.Message = fmt.Sprintf("Clock %+.0fms ±%.0fms off", ms(skew), ms(uncertainty))
+				skewedPairs++
+			}
+			result.AddSubResult(sr)
+		}
+	}
+
+	unsynced := result.Count(checker.StatusWarn)
+	switch {
+	case skewedPairs > 0 || skewedNodes > 0:
+		result.Failf("Clock skew exceeds %.0fms between %d node pairs and between %d nodes and the API server, max skew between nodes %.0fms",
+			maxSkewMS, skewedPairs/2, skewedNodes, ms(maxSkew))
+	case unreadable > 0:
+		result.Failf("Can't read the clock of %d nodes", unreadable)
+	case unsynced > 0:
+		result.Warnf("Clocks of %d nodes aren't synchronized by NTP, max skew %.0fms", unsynced, ms(maxSkew))
+	default:
+		result.Message = fmt.Sprintf("Max skew between nodes %.0fms", ms(maxSkew))
+	}
+	if apiErr != nil {
+		result.AddSubResult(checker.SubResult{
+			Object:  "api-server",
+			Status:  checker.StatusWarn,
+			Message: apiErr.Error(),
+		})
+		result.Message += ", skew to the API server not measured, its clock can't be read"
+	}
+	return result
+}
+
+// sampleNode reads the clock of a node through the exec API.
+func (cc ClockChecker) sampleNode(podName string) (Sample, error) {
+	best := Sample{Uncertainty: -1}
+	for i := 0; i < clockSamples; i++ {
+		start := time.Now()
+		output, err := cc.RunCmdInPod(podName, constant.DebugNamespace, "date +%s.%N")
+		rtt := time.Since(start)
+		if err != nil {
+			return Sample{}, fmt.Errorf("Read clock in pod %s: %v", podName, err)
+		}
+		remote, err := ParseUnixTime(strings.TrimSpace(output))
+		if err != nil {
+			return Sample{}, fmt.Errorf("Read clock in pod %s: %v", podName, err)
+		}
+		sample := Sample{
+			Offset:      remote.Sub(start.Add(rtt / 2)),
+			Uncertainty: rtt / 2,
+		}
+		if best.Uncertainty < 0 || sample.Uncertainty < best.Uncertainty {
+			best = sample
+		}
+	}
+	return best, nil
+}
+
+// sampleAPIServer reads the clock of the API server from the Date header of
+// its responses. The header has a resolution of a second, which adds half a
+// second to the uncertainty.
+func (cc ClockChecker) sampleAPIServer(ctx context.Context) (Sample, error) {
+	transport, err := rest.TransportFor(cc.PodExecConfig)
+	if err != nil {
+		return Sample{}, fmt.Errorf("Create API server transport: %v", err)
+	}
+	httpClient := &http.Client{
+		Transport: transport,
+		Timeout:   10 * time.Second,
+	}
+	url := strings.TrimSuffix(cc.PodExecConfig.Host, "/") + "/version"
+
+	best := Sample{Uncertainty: -1}
+	for i := 0; i < clockSamples; i++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return Sample{}, fmt.Errorf("Create API server request: %v", err)
+		}
+		start := time.Now()
+		resp, err := httpClient.Do(req)
+		rtt := time.Since(start)
+		if err != nil {
+			return Sample{}, fmt.Errorf("Request API server version: %v", err)
+		}
+		resp.Body.Close()
+		date, err := http.ParseTime(resp.Header.Get("Date"))
+		if err != nil {
+			return Sample{}, fmt.Errorf("Parse API server date %q: %v", resp.Header.Get("Date"), err)
+		}
+		sample := Sample{
+			Offset:      date.Add(500 * time.Millisecond).Sub(start.Add(rtt / 2)),
+			Uncertainty: rtt/2 + 500*time.Millisecond,
+		}
+		if best.Uncertainty < 0 || sample.Uncertainty < best.Uncertainty {
+			best = sample
+		}
+	}
+	return best, nil
+}
+
+// ParseUnixTime parses the seconds since the epoch with a fraction, as printed
+// by date +%s.%N.
+func ParseUnixTime(value string) (time.Time, error) {
+	parts := strings.SplitN(value, ".", 2)
+	sec, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Parse time %q: %v", value, err)
+	}
+	nsec := int64(0)
+	if len(parts) == 2 {
+		// pad or cut the fraction to nanoseconds
+		fraction := (parts[1] + "000000000")[:9]
+		if nsec, err = strconv.ParseInt(fraction, 10, 64); err != nil {
+			return time.Time{}, fmt.Errorf("Parse time %q: %v", value, err)
+		}
+	}
+	return time.Unix(sec, nsec), nil
+}
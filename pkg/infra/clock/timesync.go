@@ -0,0 +1,92 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package clock
+
+import (
+	"strings"
+)
+
+// readTimeSyncCmd lists the running time synchronization daemons and asks
+// systemd, chrony and ntpd whether the clock is synchronized. The node
+// checker pods share the PID namespace of the host, the clients run in its
+// mount namespace. The comm of systemd-timesyncd is cut to 15 characters.
+const readTimeSyncCmd = `H="nsenter -t 1 -m --"
+for d in chronyd ntpd systemd-timesyn openntpd; do pgrep -x $d >/dev/null && echo daemon=$d; done
+s=$($H timedatectl show -p NTPSynchronized --value 2>/dev/null) || s=$($H timedatectl status 2>/dev/null | awk -F': ' '/synchronized/ {print $2}')
+echo synchronized=$s
+$H chronyc -n tracking 2>/dev/null | awk -F': ' '/Leap status/ {print "chrony_leap=" $2} /System time/ {print "chrony_offset=" $2}'
+if $H which ntpstat >/dev/null 2>&1; then $H ntpstat >/dev/null 2>&1; echo ntpstat=$?; fi
+true`
+
+// TimeSync is the time synchronization state of a node.
+type TimeSync struct {
+	Daemons []string `json:"daemons,omitempty"`
+	// SystemdSynchronized is what timedatectl reports, yes or no.
+	SystemdSynchronized string `json:"systemdSynchronized,omitempty"`
+	ChronyLeapStatus    string `json:"chronyLeapStatus,omitempty"`
+	ChronyOffset        string `json:"chronyOffset,omitempty"`
+	// NTPStat is the exit code of ntpstat, 0 when synchronized.
+	NTPStat string `json:"ntpstat,omitempty"`
+}
+
+// Synchronized tells whether any source reports a synchronized clock.
+func (ts TimeSync) Synchronized() bool {
+	return ts.SystemdSynchronized == "yes" || ts.ChronyLeapStatus == "Normal" || ts.NTPStat == "0"
+}
+
+func (ts TimeSync) String() string {
+	if len(ts.Daemons) == 0 {
+		if ts.Synchronized() {
+			return "synchronized"
+		}
+		return "no NTP daemon running"
+	}
+	daemons := strings.Join(ts.Daemons, ", ")
+	if !ts.Synchronized() {
+		return daemons + " not synchronized"
+	}
+	if ts.ChronyOffset != "" {
+		return daemons + " synchronized, " + ts.ChronyOffset
+	}
+	return daemons + " synchronized"
+}
+
+// ParseTimeSync parses the key=value lines printed by readTimeSyncCmd.
+func ParseTimeSync(output string) TimeSync {
+	ts := TimeSync{}
+	for _, line := range strings.Split(output, "\n") {
+		kv := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(kv[1])
+		switch kv[0] {
+		case "daemon":
+			if value == "systemd-timesyn" {
+				value = "systemd-timesyncd"
+			}
+			ts.Daemons = append(ts.Daemons, value)
+		case "synchronized":
+			ts.SystemdSynchronized = value
+		case "chrony_leap":
+			ts.ChronyLeapStatus = value
+		case "chrony_offset":
+			ts.ChronyOffset = value
+		case "ntpstat":
+			ts.NTPStat = value
+		}
+	}
+	return ts
+}
@@ -0,0 +1,465 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+	"github.com/iomesh/debugtool/pkg/constant"
+)
+
+const (
+	kubeSystemNamespace = "kube-system"
+	// kubeDNSApp is the k8s-app label of CoreDNS as well as kube-dns.
+	kubeDNSApp      = "kube-dns"
+	nodeLocalDNSApp = "node-local-dns"
+)
+
+// DeploymentChecker inspects the cluster DNS deployment, the service in front
+// of it and the NodeLocal DNSCache. Its sub-results are named after the
+// inspected objects, or after the node of each DNS pod.
+type DeploymentChecker struct {
+	checker.Checker
+}
+
+func NewDeploymentChecker() *DeploymentChecker {
+	return &DeploymentChecker{
+		Checker: checker.Newchecker("DNSDeploymentChecker"),
+	}
+}
+
+func init() {
+	checker.Register(NewDeploymentChecker())
+}
+
+func (dc DeploymentChecker) Name() string {
+	return constant.DNSDeploymentCheckName
+}
+
+func (dc DeploymentChecker) Category() checker.Category {
+	return checker.CategoryInfra
+}
+
+func (dc DeploymentChecker) Dependencies() []string {
+	return nil
+}
+
+func (dc DeploymentChecker) Description() string {
+	return "Inspecting cluster DNS deployment"
+}
+
+func (dc DeploymentChecker) Run(ctx context.Context) checker.Result {
+	result := checker.Result{
+		Status:      checker.StatusPass,
+		Remediation: "Look at the events and logs of the cluster DNS pods in kube-system, run replicas on several nodes and fix the Corefile",
+	}
+
+	deployment, err := dc.findDeployment(ctx)
+	if err != nil {
+		result.Failf("%v", err)
+		return result
+	}
+	pods, err := dc.listPods(ctx, deployment)
+	if err != nil {
+		result.Failf("%v", err)
+		return result
+	}
+	nodeList := &corev1.NodeList{}
+	if err := dc.Client.List(ctx, nodeList, &client.ListOptions{}); err != nil {
+		result.Failf("List nodes: %v", err)
+		return result
+	}
+
+	result.AddSubResult(replicasSubResult(deployment, pods, len(nodeList.Items)))
+	for _, pod := range pods {
+		result.AddSubResult(podSubResult(pod))
+	}
+	result.AddSubResult(dc.configSubResult(ctx, deployment))
+	result.AddSubResult(dc.endpointsSubResult(ctx, pods))
+	result.AddSubResult(dc.nodeLocalDNSSubResult(ctx))
+
+	// the failure message lists every finding, not just the first one
+	findings := []string{}
+	for _, sr := range result.SubResults {
+		if sr.Status != checker.StatusPass {
+			findings = append(findings, fmt.Sprintf("%s: %s", sr.Name(), sr.Message))
+		}
+	}
+	switch result.Status {
+	case checker.StatusFail:
+		result.Failf("%s", strings.Join(findings, "; "))
+	case checker.StatusWarn:
+		result.Warnf("%s", strings.Join(findings, "; "))
+	default:
+		result.Message = fmt.Sprintf("%s %s has %d ready replicas", deployment.Name, deploymentImageTag(deployment), deployment.Status.ReadyReplicas)
+	}
+	return result
+}
+
+// findDeployment returns the CoreDNS or kube-dns deployment, both carry the
+// k8s-app=kube-dns label.
+func (dc DeploymentChecker) findDeployment(ctx context.Context) (*appsv1.Deployment, error) {
+	deploymentList := &appsv1.DeploymentList{}
+	err := dc.Client.List(ctx, deploymentList, &client.ListOptions{
+		Namespace: kubeSystemNamespace,
+		LabelSelector: labels.SelectorFromSet(map[string]string{
+			"k8s-app": kubeDNSApp,
+		}),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("List cluster DNS deployments: %v", err)
+	}
+	if len(deploymentList.Items) > 0 {
+		return &deploymentList.Items[0], nil
+	}
+	for _, name := range []string{"coredns", "kube-dns"} {
+		deployment := &appsv1.Deployment{}
+		deploymentLookupKey := types.NamespacedName{
+			Name:      name,
+			Namespace: kubeSystemNamespace,
+		}
+		if err := dc.Client.Get(ctx, deploymentLookupKey, deployment); err == nil {
+			return deployment, nil
+		}
+	}
+	return nil, errors.New("No CoreDNS or kube-dns deployment in kube-system")
+}
+
+func (dc DeploymentChecker) listPods(ctx context.Context, deployment *appsv1.Deployment) ([]corev1.Pod, error) {
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("Parse selector of deployment %s: %v", deployment.Name, err)
+	}
+	podList := &corev1.PodList{}
+	err = dc.Client.List(ctx, podList, &client.ListOptions{
+		Namespace:     deployment.Namespace,
+		LabelSelector: selector,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("List pods of deployment %s: %v", deployment.Name, err)
+	}
+	pods := podList.Items
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].Name < pods[j].Name
+	})
+	return pods, nil
+}
+
+// replicasSubResult grades the readiness of the replicas and how they are
+// spread over the nodes.
+func replicasSubResult(deployment *appsv1.Deployment, pods []corev1.Pod, nodeCount int) checker.SubResult {
+	sr := checker.SubResult{
+		Object: "deployment/" + deployment.Name,
+		Status: checker.StatusPass,
+	}
+	desired := int32(1)
+	if deployment.Spec.Replicas != nil {
+		desired = *deployment.Spec.Replicas
+	}
+	ready := deployment.Status.ReadyReplicas
+	sr.Measurements = []checker.Measurement{
+		{Name: "desired", Value: float64(desired)},
+		{Name: "ready", Value: float64(ready)},
+	}
+
+	nodes := map[string]int{}
+	for _, pod := range pods {
+		if pod.Spec.NodeName != "" {
+			nodes[pod.Spec.NodeName]++
+		}
+	}
+	nodeNames := []string{}
+	for node := range nodes {
+		nodeNames = append(nodeNames, node)
+	}
+	sort.Strings(nodeNames)
+
+	switch {
+	case ready == 0:
+		sr.Status = checker.StatusFail
+		sr.Message = fmt.Sprintf("none of %d replicas ready", desired)
+	case ready < desired:
+		sr.Status = checker.StatusWarn
+		sr.Message = fmt.Sprintf("%d of %d replicas ready", ready, desired)
+	case nodeCount > 1 && desired == 1:
+		sr.Status = checker.StatusWarn
+		sr.Message = "a single replica, DNS fails with its node"
+	case nodeCount > 1 && len(nodeNames) == 1:
+		sr.Status = checker.StatusWarn
+		sr.Message = fmt.Sprintf("all %d replicas run on %s", len(pods), nodeNames[0])
+	default:
+		sr.Message = fmt.Sprintf("%d of %d replicas ready on %s", ready, desired, strings.Join(nodeNames, ", "))
+	}
+	return sr
+}
+
+// podSubResult reports the readiness and restarts of a DNS pod. It is named
+// after the pod, several DNS pods may run on the same node.
+func podSubResult(pod corev1.Pod) checker.SubResult {
+	sr := checker.SubResult{
+		Node:   pod.Spec.NodeName,
+		Object: "pod/" + pod.Name,
+		Status: checker.StatusPass,
+	}
+
+	restarts := int32(0)
+	reasons := []string{}
+	for _, cs := range pod.Status.ContainerStatuses {
+		restarts += cs.RestartCount
+		if cs.State.Waiting != nil && cs.State.Waiting.Reason != "" {
+			reasons = append(reasons, cs.State.Waiting.Reason)
+		}
+		if cs.LastTerminationState.Terminated != nil && cs.LastTerminationState.Terminated.Reason != "" {
+			reasons = append(reasons, "last terminated "+cs.LastTerminationState.Terminated.Reason)
+		}
+	}
+	sr.Measurements = []checker.Measurement{
+		{Name: "restarts", Value: float64(restarts)},
+	}
+
+	ready := false
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
+			ready = true
+		}
+	}
+	state := "ready"
+	switch {
+	case !ready:
+		sr.Status = checker.StatusFail
+		state = fmt.Sprintf("not ready (%s)", pod.Status.Phase)
+	case restarts > 0:
+		sr.Status = checker.StatusWarn
+	}
+	sr.Message = fmt.Sprintf("%s %s, %d restarts", pod.Name, state, restarts)
+	if len(reasons) > 0 {
+		sr.Message += fmt.Sprintf(" (%s)", strings.Join(reasons, ", "))
+	}
+	return sr
+}
+
+// configSubResult reads the Corefile of CoreDNS, or the config map of
+// kube-dns, and looks for the plugins cluster DNS can't work without.
+func (dc DeploymentChecker) configSubResult(ctx context.Context, deployment *appsv1.Deployment) checker.SubResult {
+	sr := checker.SubResult{
+		Object: "configmap/" + deployment.Name,
+		Status: checker.StatusPass,
+	}
+	configMap := &corev1.ConfigMap{}
+	configMapLookupKey := types.NamespacedName{
+		Name:      deployment.Name,
+		Namespace: deployment.Namespace,
+	}
+	if err := dc.Client.Get(ctx, configMapLookupKey, configMap); err != nil {
+		if deployment.Name == "kube-dns" {
+			// kube-dns runs with its defaults without a config map
+			sr.Message = "not present, kube-dns uses its defaults"
+			return sr
+		}
+		sr.Status = checker.StatusFail
+		sr.Message = fmt.Sprintf("can't be read: %v", err)
+		return sr
+	}
+	sr.Details = configMap.Data
+
+	corefile, ok := configMap.Data["Corefile"]
+	if !ok {
+		sr.Message = fmt.Sprintf("keys %s", strings.Join(configMapKeys(configMap), ", "))
+		return sr
+	}
+	plugins := CorefilePlugins(corefile)
+	problems := []string{}
+	if _, ok := plugins["kubernetes"]; !ok {
+		sr.Status = checker.StatusFail
+		problems = append(problems, "Corefile has no kubernetes plugin, cluster names can't be resolved")
+	}
+	upstream, forwards := plugins["forward"]
+	if !forwards {
+		upstream, forwards = plugins["proxy"]
+	}
+	if !forwards {
+		sr.Status = checker.Worse(sr.Status, checker.StatusWarn)
+		problems = append(problems, "Corefile forwards no query upstream, external names can't be resolved")
+	}
+	if len(problems) > 0 {
+		sr.Message = strings.Join(problems, ", ")
+		return sr
+	}
+	sr.Message = fmt.Sprintf("kubernetes %s, forward %s", plugins["kubernetes"], upstream)
+	return sr
+}
+
+// endpointsSubResult checks the kube-dns service is backed by every ready DNS
+// pod.
+func (dc DeploymentChecker) endpointsSubResult(ctx context.Context, pods []corev1.Pod) checker.SubResult {
+	sr := checker.SubResult{
+		Object: "service/kube-dns",
+		Status: checker.StatusPass,
+	}
+	endpoints := &corev1.Endpoints{}
+	endpointsLookupKey := types.NamespacedName{
+		Name:      "kube-dns",
+		Namespace: kubeSystemNamespace,
+	}
+	if err := dc.Client.Get(ctx, endpointsLookupKey, endpoints); err != nil {
+		sr.Status = checker.StatusFail
+		sr.Message = fmt.Sprintf("endpoints can't be read: %v", err)
+		return sr
+	}
+	ready, notReady := map[string]bool{}, map[string]bool{}
+	for _, subset := range endpoints.Subsets {
+		for _, addr := range subset.Addresses {
+			ready[addr.IP] = true
+		}
+		for _, addr := range subset.NotReadyAddresses {
+			notReady[addr.IP] = true
+		}
+	}
+	readyPods := 0
+	for _, pod := range pods {
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
+				readyPods++
+			}
+		}
+	}
+	sr.Measurements = []checker.Measurement{
+		{Name: "ready endpoints", Value: float64(len(ready))},
+	}
+
+	switch {
+	case len(ready) == 0:
+		sr.Status = checker.StatusFail
+		sr.Message = "no ready endpoint, pods can't reach the cluster DNS"
+	case len(notReady) > 0:
+		sr.Status = checker.StatusWarn
+		sr.Message = fmt.Sprintf("%d ready and %d not ready endpoints", len(ready), len(notReady))
+	case len(ready) < readyPods:
+		sr.Status = checker.StatusWarn
+		sr.Message = fmt.Sprintf("%d endpoints for %d ready DNS pods", len(ready), readyPods)
+	default:
+		sr.Message = fmt.Sprintf("%d ready endpoints", len(ready))
+	}
+	return sr
+}
+
+// nodeLocalDNSSubResult reports the NodeLocal DNSCache daemonset. Pods on a
+// node where it isn't running can't resolve anything once the kubelet
+// points them to the local cache.
+func (dc DeploymentChecker) nodeLocalDNSSubResult(ctx context.Context) checker.SubResult {
+	sr := checker.SubResult{
+		Object: "daemonset/" + nodeLocalDNSApp,
+		Status: checker.StatusPass,
+	}
+	dsList := &appsv1.DaemonSetList{}
+	err := dc.Client.List(ctx, dsList, &client.ListOptions{
+		Namespace: kubeSystemNamespace,
+		LabelSelector: labels.SelectorFromSet(map[string]string{
+			"k8s-app": nodeLocalDNSApp,
+		}),
+	})
+	if err != nil {
+		sr.Status = checker.StatusWarn
+		sr.Message = fmt.Sprintf("can't be listed: %v", err)
+		return sr
+	}
+	if len(dsList.Items) == 0 {
+		sr.Message = "NodeLocal DNSCache not deployed"
+		return sr
+	}
+
+	ds := dsList.Items[0]
+	sr.Object = "daemonset/" + ds.Name
+	desired, ready := ds.Status.DesiredNumberScheduled, ds.Status.NumberReady
+	sr.Measurements = []checker.Measurement{
+		{Name: "desired", Value: float64(desired)},
+		{Name: "ready", Value: float64(ready)},
+	}
+	if ready < desired {
+		sr.Status = checker.StatusFail
+		sr.Message = fmt.Sprintf("NodeLocal DNSCache ready on %d of %d nodes", ready, desired)
+		return sr
+	}
+	sr.Message = fmt.Sprintf("NodeLocal DNSCache ready on %d nodes", ready)
+	return sr
+}
+
+// CorefilePlugins returns the plugins of the server blocks of a Corefile with
+// their arguments. A plugin used by several server blocks keeps the
+// arguments of the first.
+func CorefilePlugins(corefile string) map[string]string {
+	plugins := map[string]string{}
+	depth := 0
+	for _, line := range strings.Split(corefile, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			continue
+		case line == "}":
+			depth--
+			continue
+		}
+		// .:53 { opens a server block, kubernetes cluster.local { the
+		// options of a plugin
+		fields := strings.Fields(strings.TrimSuffix(line, "{"))
+		if depth == 1 && len(fields) > 0 {
+			if _, ok := plugins[fields[0]]; !ok {
+				plugins[fields[0]] = strings.Join(fields[1:], " ")
+			}
+		}
+		if strings.HasSuffix(line, "{") {
+			depth++
+		}
+	}
+	return plugins
+}
+
+func configMapKeys(configMap *corev1.ConfigMap) []string {
+	keys := []string{}
+	for key := range configMap.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// deploymentImageTag returns the tag of the first container image, which is
+// the version of CoreDNS or kube-dns.
+func deploymentImageTag(deployment *appsv1.Deployment) string {
+	containers := deployment.Spec.Template.Spec.Containers
+	if len(containers) == 0 {
+		return ""
+	}
+	image := containers[0].Image
+	if i := strings.LastIndex(image, ":"); i >= 0 && !strings.Contains(image[i:], "/") {
+		return image[i+1:]
+	}
+	return "latest"
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package dns
+
+import (
+	"strconv"
+	"strings"
+)
+
+// DigResult is the outcome of a single dig query.
+type DigResult struct {
+	// Status is the response code, such as NOERROR or NXDOMAIN, or
+	// TIMEOUT when no server answered.
+	Status      string      `json:"status"`
+	Answers     []DigAnswer `json:"answers,omitempty"`
+	QueryTimeMS float64     `json:"queryTimeMS"`
+	// Server is the address of the server that answered.
+	Server string `json:"server,omitempty"`
+}
+
+type DigAnswer struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Data string `json:"data"`
+}
+
+// OK tells whether the query succeeded with at least one answer.
+func (dr DigResult) OK() bool {
+	return dr.Status == "NOERROR" && len(dr.Answers) > 0
+}
+
+// Data returns the data of the answers of the given type, without the
+// trailing dot of names and the quotes of texts.
+func (dr DigResult) Data(recordType string) []string {
+	data := []string{}
+	for _, answer := range dr.Answers {
+		if answer.Type == recordType {
+			data = append(data, strings.Trim(strings.TrimSuffix(answer.Data, "."), `"`))
+		}
+	}
+	return data
+}
+
+// ParseDig parses the default output of dig.
+func ParseDig(output string) DigResult {
+	dr := DigResult{Status: "TIMEOUT"}
+	inAnswer := false
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.Contains(line, "->>HEADER<<-"):
+			// ;; ->>HEADER<<- opcode: QUERY, status: NOERROR, id: 41420
+			for _, part := range strings.Split(line, ",") {
+				kv := strings.SplitN(strings.TrimSpace(part), ":", 2)
+				if len(kv) == 2 && kv[0] == "status" {
+					dr.Status = strings.TrimSpace(kv[1])
+				}
+			}
+		case strings.HasPrefix(line, ";; ANSWER SECTION:"):
+			inAnswer = true
+		case strings.HasPrefix(line, ";; Query time:"):
+			// ;; Query time: 3 msec
+			fields := strings.Fields(line)
+			if len(fields) >= 4 {
+				dr.QueryTimeMS, _ = strconv.ParseFloat(fields[3], 64)
+			}
+		case strings.HasPrefix(line, ";; SERVER:"):
+			// ;; SERVER: 10.96.0.10#53(10.96.0.10)
+			server := strings.TrimSpace(strings.TrimPrefix(line, ";; SERVER:"))
+			dr.Server = strings.SplitN(server, "#", 2)[0]
+		case line == "" || strings.HasPrefix(line, ";"):
+			inAnswer = false
+		case inAnswer:
+			// iomesh-debug.iomesh-debug.svc.cluster.local. 30 IN A 10.96.12.7
+			fields := strings.Fields(line)
+			if len(fields) >= 5 {
+				dr.Answers = append(dr.Answers, DigAnswer{
+					Name: strings.TrimSuffix(fields[0], "."),
+					Type: fields[3],
+					Data: strings.Join(fields[4:], " "),
+				})
+			}
+		}
+	}
+	return dr
+}
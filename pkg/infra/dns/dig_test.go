@@ -0,0 +1,148 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package dns
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDig(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   DigResult
+		ok     bool
+		data   map[string][]string
+	}{
+		{
+			name:   "empty output",
+			output: "",
+			want:   DigResult{Status: "TIMEOUT"},
+		},
+		{
+			name: "timeout",
+			output: `
+; <<>> DiG 9.16.1 <<>> iomesh-debug.iomesh-debug.svc.cluster.local
+;; global options: +cmd
+;; connection timed out; no servers could be reached
+`,
+			want: DigResult{Status: "TIMEOUT"},
+		},
+		{
+			name:   "garbage",
+			output: "dig: command not found\n->>HEADER<<- status\n;; Query time: soon",
+			want:   DigResult{Status: "TIMEOUT"},
+		},
+		{
+			name: "ipv4 answer",
+			output: `
+; <<>> DiG 9.16.1 <<>> iomesh-debug.iomesh-debug.svc.cluster.local
+;; global options: +cmd
+;; Got answer:
+;; ->>HEADER<<- opcode: QUERY, status: NOERROR, id: 41420
+;; flags: qr aa rd; QUERY: 1, ANSWER: 1, AUTHORITY: 0, ADDITIONAL: 1
+
+;; QUESTION SECTION:
+;iomesh-debug.iomesh-debug.svc.cluster.local. IN	A
+
+;; ANSWER SECTION:
+iomesh-debug.iomesh-debug.svc.cluster.local. 30	IN A 10.96.12.7
+
+;; Query time: 3 msec
+;; SERVER: 10.96.0.10#53(10.96.0.10)
+;; WHEN: Mon Jan 01 00:00:00 UTC 2021
+;; MSG SIZE  rcvd: 131
+`,
+			want: DigResult{
+				Status:      "NOERROR",
+				Answers:     []DigAnswer{{Name: "iomesh-debug.iomesh-debug.svc.cluster.local", Type: "A", Data: "10.96.12.7"}},
+				QueryTimeMS: 3,
+				Server:      "10.96.0.10",
+			},
+			ok:   true,
+			data: map[string][]string{"A": {"10.96.12.7"}, "AAAA": {}},
+		},
+		{
+			name: "ipv6 answers from an ipv6 server",
+			output: `
+;; ->>HEADER<<- opcode: QUERY, status: NOERROR, id: 7
+;; ANSWER SECTION:
+iomesh-debug-headless.iomesh-debug.svc.cluster.local. 5 IN AAAA fd00:10:244::5
+iomesh-debug-headless.iomesh-debug.svc.cluster.local. 5 IN AAAA fd00:10:244:1::7
+
+;; Query time: 1 msec
+;; SERVER: fd00:10:96::a#53(fd00:10:96::a) (UDP)
+`,
+			want: DigResult{
+				Status: "NOERROR",
+				Answers: []DigAnswer{
+					{Name: "iomesh-debug-headless.iomesh-debug.svc.cluster.local", Type: "AAAA", Data: "fd00:10:244::5"},
+					{Name: "iomesh-debug-headless.iomesh-debug.svc.cluster.local", Type: "AAAA", Data: "fd00:10:244:1::7"},
+				},
+				QueryTimeMS: 1,
+				Server:      "fd00:10:96::a",
+			},
+			ok:   true,
+			data: map[string][]string{"AAAA": {"fd00:10:244::5", "fd00:10:244:1::7"}},
+		},
+		{
+			name: "reverse and txt records",
+			output: `
+;; ->>HEADER<<- opcode: QUERY, status: NOERROR, id: 9
+;; ANSWER SECTION:
+7.12.96.10.in-addr.arpa. 30 IN PTR iomesh-debug.iomesh-debug.svc.cluster.local.
+hostname.bind. 0 CH TXT "coredns-74ff55c5b-x2x7q"
+`,
+			want: DigResult{
+				Status: "NOERROR",
+				Answers: []DigAnswer{
+					{Name: "7.12.96.10.in-addr.arpa", Type: "PTR", Data: "iomesh-debug.iomesh-debug.svc.cluster.local."},
+					{Name: "hostname.bind", Type: "TXT", Data: `"coredns-74ff55c5b-x2x7q"`},
+				},
+			},
+			ok: true,
+			data: map[string][]string{
+				"PTR": {"iomesh-debug.iomesh-debug.svc.cluster.local"},
+				"TXT": {"coredns-74ff55c5b-x2x7q"},
+			},
+		},
+		{
+			name: "nxdomain",
+			output: `
+;; ->>HEADER<<- opcode: QUERY, status: NXDOMAIN, id: 3
+;; Query time: 0 msec
+;; SERVER: 10.96.0.10#53(10.96.0.10)
+`,
+			want: DigResult{Status: "NXDOMAIN", Server: "10.96.0.10"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseDig(tt.output)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseDig() = %+v, want %+v", got, tt.want)
+			}
+			if got.OK() != tt.ok {
+				t.Errorf("OK() = %v, want %v", got.OK(), tt.ok)
+			}
+			for recordType, want := range tt.data {
+				if data := got.Data(recordType); !reflect.DeepEqual(data, want) {
+					t.Errorf("Data(%s) = %v, want %v", recordType, data, want)
+				}
+			}
+		})
+	}
+}
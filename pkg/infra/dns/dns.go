@@ -16,17 +16,37 @@ package dns
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
 
-	"github.com/enescakir/emoji"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/labels"
-	"sigs.k8s.io/controller-runtime/pkg/client"
+	"k8s.io/apimachinery/pkg/types"
 
 	"github.com/iomesh/debugtool/pkg/checker"
 	"github.com/iomesh/debugtool/pkg/constant"
+	"github.com/iomesh/debugtool/pkg/fixture"
 	"github.com/iomesh/debugtool/pkg/kutils"
+	"github.com/iomesh/debugtool/pkg/network"
+)
+
+// readDomainCmd prints the cluster domain, taken from the svc.<domain> entry
+// of the search list of the pod.
+const readDomainCmd = `D=$(awk '/^search/ {for (i = 2; i <= NF; i++) if ($i ~ /^svc\./) {print substr($i, 5); exit}}' /etc/resolv.conf)
+echo "### domain ${D:-cluster.local}"
+`
+
+// Names of the queries run from every pod.
+const (
+	queryShort    = "short"
+	queryFQDN     = "fqdn"
+	queryHeadless = "headless"
+	queryReverse  = "reverse"
+	queryHostname = "hostname"
+	// queryServer prefixes the queries sent to each cluster DNS pod.
+	queryServer = "server/"
 )
 
 type DNSChecker struct {
@@ -39,54 +59,320 @@ func NewDNSChecker() *DNSChecker {
 	}
 }
 
-func (dc DNSChecker) Check() error {
-	dc.Spinner.Start()
-	// create debug service
-	service := kutils.NewService(constant.DebugNamespace, "iomesh-debug")
-	service.Spec.Ports = []corev1.ServicePort{
-		{
-			Port: 5201,
-		},
+func init() {
+	checker.Register(NewDNSChecker())
+}
+
+func (dc DNSChecker) Name() string {
+	return constant.DNSCheckName
+}
+
+func (dc DNSChecker) Category() checker.Category {
+	return checker.CategoryInfra
+}
+
+// Dependencies of the DNS check: resolving a service name needs a working
+// pod network to reach the cluster DNS.
+func (dc DNSChecker) Dependencies() []string {
+	return []string{constant.CNIConnectivityCheckName}
+}
+
+func (dc DNSChecker) Description() string {
+	return "Checking Coredns working"
+}
+
+// dnsServer is a pod behind the cluster DNS service.
+type dnsServer struct {
+	Pod  string
+	Node string
+	IP   string
+}
+
+// nodeDNS is what the DNS queries of the basic checker pod of a node
+// returned, indexed by query name.
+type nodeDNS struct {
+	Domain  string               `json:"domain"`
+	Queries map[string]DigResult `json:"queries"`
+}
+
+func (dc DNSChecker) Run(ctx context.Context) checker.Result {
+	result := checker.Result{
+		Status:      checker.StatusPass,
+		Remediation: "Check if the cluster DNS (CoreDNS) pods are running and reachable, and the resolv.conf the kubelet gives to pods",
 	}
-	err := dc.Client.Create(context.TODO(), service)
+
+	service, err := fixture.GetInstance().EnsureDebugService(ctx)
 	if err != nil {
-		dc.SpinnerStop(emoji.CrossMark)
-		return err
+		result.Failf("%v", err)
+		return result
+	}
+	if _, err := fixture.GetInstance().EnsureDebugHeadlessService(ctx); err != nil {
+		result.Failf("%v", err)
+		return result
 	}
 
-	// check nslookup debug service
-	podList := &corev1.PodList{}
-	err = dc.Client.List(context.TODO(), podList, &client.ListOptions{
-		Namespace: constant.DebugNamespace,
-		LabelSelector: labels.SelectorFromSet(map[string]string{
-			"app": constant.BasicCheckerLabel,
-		}),
+	pods, err := kutils.ListPods(ctx, dc.Client, constant.DebugNamespace, constant.BasicCheckerLabel)
+	if err != nil {
+		result.Failf("List basic checker pods: %v", err)
+		return result
+	}
+	if len(pods) < 2 {
+		result.Failf("Num of nodes less than 2")
+		return result
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].Spec.NodeName < pods[j].Spec.NodeName
 	})
+	if err := kutils.WaitEndpointsReady(dc.Client, constant.DebugNamespace, constant.DebugHeadlessServiceName, len(pods)); err != nil {
+		result.Failf("Service %s has not every basic checker pod as endpoint: %v", constant.DebugHeadlessServiceName, err)
+		return result
+	}
+
+	servers, err := dc.dnsServers(ctx)
 	if err != nil {
-		dc.SpinnerStop(emoji.CrossMark)
-		return fmt.Errorf("List basic checker pods: %v", err)
+		dc.Log.Error(err, "List cluster DNS pods fail")
+	}
+
+	// the records are looked up in the family of the cluster IP, the
+	// headless service holds the pod addresses of that family
+	clusterIP := service.Spec.ClusterIP
+	family := network.FamilyOf(clusterIP)
+	podIPs := []string{}
+	for _, pod := range pods {
+		if ip := network.PodIP(pod, family); ip != "" {
+			podIPs = append(podIPs, ip)
+		}
+	}
+
+	subResults := make([]checker.SubResult, len(pods))
+	failingServers := make([][]string, len(pods))
+	var wg sync.WaitGroup
+	for i := range pods {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			subResults[i], failingServers[i] = dc.resolve(pods[i], clusterIP, family, podIPs, servers)
+		}(i)
+	}
+	wg.Wait()
+
+	failedNodes := 0
+	for _, sr := range subResults {
+		if sr.Status == checker.StatusFail {
+			failedNodes++
+		}
+		result.AddSubResult(sr)
+	}
+	if failedNodes > 0 {
+		result.Failf("DNS resolution fails on %d of %d nodes%s", failedNodes, len(pods), brokenServers(servers, failingServers))
+		return result
+	}
+	result.Message = fmt.Sprintf("Resolved from %d nodes through %d DNS pods", len(pods), len(servers))
+	return result
+}
+
+// dnsServers lists the pods behind the kube-dns service, which fronts CoreDNS
+// as well as kube-dns.
+func (dc DNSChecker) dnsServers(ctx context.Context) ([]dnsServer, error) {
+	endpoints := &corev1.Endpoints{}
+	endpointsLookupKey := types.NamespacedName{
+		Name:      "kube-dns",
+		Namespace: "kube-system",
 	}
-	if len(podList.Items) < 2 {
-		dc.SpinnerStop(emoji.CrossMark)
-		return errors.New("Num of nodes less than 2")
+	if err := dc.Client.Get(ctx, endpointsLookupKey, endpoints); err != nil {
+		return nil, fmt.Errorf("Get kube-dns endpoints: %v", err)
 	}
+	servers := []dnsServer{}
+	seen := map[string]bool{}
+	for _, subset := range endpoints.Subsets {
+		for _, addr := range subset.Addresses {
+			if seen[addr.IP] {
+				continue
+			}
+			seen[addr.IP] = true
+			server := dnsServer{IP: addr.IP, Pod: addr.IP}
+			if addr.TargetRef != nil {
+				server.Pod = addr.TargetRef.Name
+			}
+			if addr.NodeName != nil {
+				server.Node = *addr.NodeName
+			}
+			servers = append(servers, server)
+		}
+	}
+	sort.Slice(servers, func(i, j int) bool {
+		return servers[i].Pod < servers[j].Pod
+	})
+	return servers, nil
+}
 
-	checkDNSCmd := "host iomesh-debug"
-	_, err = dc.RunCmdInPod(podList.Items[0].Name, constant.DebugNamespace, checkDNSCmd)
+// resolve runs every query from the pod in a single command and grades the
+// answers. The DNS pods that didn't answer are returned too.
+func (dc DNSChecker) resolve(pod corev1.Pod, clusterIP string, family network.Family, podIPs []string, servers []dnsServer) (checker.SubResult, []string) {
+	sr := checker.SubResult{
+		Node:   pod.Spec.NodeName,
+		Status: checker.StatusPass,
+	}
+	recordType := "A"
+	if family == network.IPv6 {
+		recordType = "AAAA"
+	}
+	fqdn := fmt.Sprintf("%s.%s.svc.$D", constant.DebugServiceName, constant.DebugNamespace)
+	headless := fmt.Sprintf("%s.%s.svc.$D", constant.DebugHeadlessServiceName, constant.DebugNamespace)
+
+	resolveCmd := readDomainCmd + `D=${D:-cluster.local}
+q() { echo "### $1"; shift; dig +time=2 +tries=1 "$@"; }
+`
+	resolveCmd += fmt.Sprintf("q %s +search %s %s\n", queryShort, constant.DebugServiceName, recordType)
+	resolveCmd += fmt.Sprintf("q %s %s %s\n", queryFQDN, fqdn, recordType)
+	resolveCmd += fmt.Sprintf("q %s %s %s\n", queryHeadless, headless, recordType)
+	resolveCmd += fmt.Sprintf("q %s -x %s\n", queryReverse, clusterIP)
+	resolveCmd += fmt.Sprintf("q %s CH TXT hostname.bind\n", queryHostname)
+	for _, server := range servers {
+		resolveCmd += fmt.Sprintf("q %s%s @%s %s %s\n", queryServer, server.Pod, server.IP, fqdn, recordType)
+	}
+	resolveCmd += "true"
+
+	output, err := dc.RunCmdInPod(pod.Name, constant.DebugNamespace, resolveCmd)
 	if err != nil {
-		dc.SpinnerStop(emoji.CrossMark)
-		return fmt.Errorf("Can't resolute service iomesh-debug, DNS service not working")
+		sr.Status = checker.StatusFail
+		sr.Message = fmt.Sprintf("Run dig in pod %s: %v", pod.Name, err)
+		return sr, nil
+	}
+	nd := parseQueries(output)
+	sr.Details = nd
+	serviceFQDN := fmt.Sprintf("%s.%s.svc.%s", constant.DebugServiceName, constant.DebugNamespace, nd.Domain)
+
+	problems := []string{}
+	for _, name := range []string{queryShort, queryFQDN} {
+		if dr := nd.Queries[name]; !containsIP(dr.Data(recordType), clusterIP) {
+			problems = append(problems, fmt.Sprintf("%s name %s", name, describeFailure(dr)))
+		}
+	}
+	missing := 0
+	answered := nd.Queries[queryHeadless].Data(recordType)
+	for _, ip := range podIPs {
+		if !containsIP(answered, ip) {
+			missing++
+		}
+	}
+	if missing > 0 {
+		problems = append(problems, fmt.Sprintf("headless service lacks %d of %d pod records", missing, len(podIPs)))
+	}
+	if dr := nd.Queries[queryReverse]; !contains(dr.Data("PTR"), serviceFQDN) {
+		problems = append(problems, fmt.Sprintf("reverse lookup of %s %s", clusterIP, describeFailure(dr)))
+	}
+
+	sr.Measurements = []checker.Measurement{
+		{Name: "latency", Value: nd.Queries[queryFQDN].QueryTimeMS, Unit: "ms"},
+	}
+	failing := []string{}
+	for _, server := range servers {
+		dr := nd.Queries[queryServer+server.Pod]
+		if !containsIP(dr.Data(recordType), clusterIP) {
+			failing = append(failing, server.Pod)
+			problems = append(problems, fmt.Sprintf("DNS pod %s %s", server.Pod, describeFailure(dr)))
+			continue
+		}
+		sr.Measurements = append(sr.Measurements, checker.Measurement{Name: server.Pod, Value: dr.QueryTimeMS, Unit: "ms"})
+	}
+
+	if len(problems) > 0 {
+		sr.Status = checker.StatusFail
+		sr.Message = strings.Join(problems, ", ")
+		return sr, failing
+	}
+	sr.Message = fmt.Sprintf("Resolved by %s", nd.Queries[queryFQDN].Server)
+	// CoreDNS only tells its hostname with the chaos plugin, other
+	// answers come from an upstream server and are ignored
+	for _, hostname := range nd.Queries[queryHostname].Data("TXT") {
+		for _, server := range servers {
+			if hostname == server.Pod {
+				sr.Message += fmt.Sprintf(", answered by DNS pod %s", hostname)
+			}
+		}
 	}
-	dc.SpinnerStop(emoji.CheckMarkButton)
-	return nil
+	return sr, nil
 }
 
-func (dc DNSChecker) SpinnerStart() {
-	dc.Spinner.Suffix = " Checking Coredns working"
-	dc.Spinner.Start()
+// parseQueries splits the output of the queries at their ### markers.
+func parseQueries(output string) nodeDNS {
+	nd := nodeDNS{
+		Domain:  "cluster.local",
+		Queries: map[string]DigResult{},
+	}
+	name := ""
+	sections := map[string][]string{}
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.HasPrefix(line, "### ") {
+			if name != "" {
+				sections[name] = append(sections[name], line)
+			}
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 3 && fields[1] == "domain" {
+			nd.Domain = fields[2]
+			continue
+		}
+		name = strings.TrimPrefix(line, "### ")
+		sections[name] = []string{}
+	}
+	for name, lines := range sections {
+		nd.Queries[name] = ParseDig(strings.Join(lines, "\n"))
+	}
+	return nd
 }
 
-func (dc DNSChecker) SpinnerStop(emoji emoji.Emoji) {
-	dc.Spinner.FinalMSG = fmt.Sprintf("%v Checking Coredns working\n", emoji)
-	dc.Spinner.Stop()
+func describeFailure(dr DigResult) string {
+	switch {
+	case dr.Status == "TIMEOUT":
+		return "timed out"
+	case dr.Status != "NOERROR":
+		return "failed with " + dr.Status
+	default:
+		return "returned an unexpected answer"
+	}
+}
+
+// brokenServers names the DNS pods that failed from some node, they are the
+// likely culprit when only some queries fail.
+func brokenServers(servers []dnsServer, failingServers [][]string) string {
+	failing := map[string]int{}
+	for _, pods := range failingServers {
+		for _, pod := range pods {
+			failing[pod]++
+		}
+	}
+	broken := []string{}
+	for _, server := range servers {
+		if failing[server.Pod] > 0 {
+			broken = append(broken, fmt.Sprintf("%s on %s fails from %d nodes", server.Pod, server.Node, failing[server.Pod]))
+		}
+	}
+	if len(broken) == 0 {
+		return ""
+	}
+	return ", DNS pod " + strings.Join(broken, ", ")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// containsIP is like contains but compares addresses, which may be written
+// differently in IPv6.
+func containsIP(values []string, ip string) bool {
+	parsed := net.ParseIP(ip)
+	for _, v := range values {
+		if parsed != nil && parsed.Equal(net.ParseIP(v)) {
+			return true
+		}
+	}
+	return false
 }
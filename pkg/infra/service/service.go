@@ -0,0 +1,227 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+	"github.com/iomesh/debugtool/pkg/constant"
+	"github.com/iomesh/debugtool/pkg/fixture"
+	"github.com/iomesh/debugtool/pkg/kutils"
+)
+
+type ServiceChecker struct {
+	checker.Checker
+}
+
+func NewServiceChecker() *ServiceChecker {
+	return &ServiceChecker{
+		Checker: checker.Newchecker("ServiceChecker"),
+	}
+}
+
+func init() {
+	checker.Register(NewServiceChecker())
+}
+
+func (sc ServiceChecker) Name() string {
+	return constant.ServiceDatapathCheckName
+}
+
+func (sc ServiceChecker) Category() checker.Category {
+	return checker.CategoryInfra
+}
+
+// Dependencies of the service check: the service is backed by the basic
+// checker pods, which must reach each other first.
+func (sc ServiceChecker) Dependencies() []string {
+	return []string{constant.CNIConnectivityCheckName}
+}
+
+func (sc ServiceChecker) Description() string {
+	return "Checking Service datapath"
+}
+
+func (sc ServiceChecker) Run(ctx context.Context) checker.Result {
+	result := checker.Result{
+		Status:      checker.StatusPass,
+		Remediation: "Check that kube-proxy (or its replacement) runs on every node and its rules are in sync",
+	}
+
+	service, err := fixture.GetInstance().EnsureDebugService(ctx)
+	if err != nil {
+		result.Failf("%v", err)
+		return result
+	}
+	if err := kutils.WaitEndpointsReady(sc.Client, constant.DebugNamespace, constant.DebugServiceName, 1); err != nil {
+		result.Failf("Service %s has no ready endpoints: %v", constant.DebugServiceName, err)
+		return result
+	}
+	nodePort := int32(0)
+	if len(service.Spec.Ports) > 0 {
+		nodePort = service.Spec.Ports[0].NodePort
+	}
+
+	pods, err := kutils.ListPods(ctx, sc.Client, constant.DebugNamespace, constant.BasicCheckerLabel)
+	if err != nil {
+		result.Failf("List basic checker pods: %v", err)
+		return result
+	}
+	nodeIPs, err := sc.nodeInternalIPs(ctx)
+	if err != nil {
+		result.Failf("%v", err)
+		return result
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].Spec.NodeName < pods[j].Spec.NodeName
+	})
+
+	subResults := make([][]checker.SubResult, len(pods))
+	var wg sync.WaitGroup
+	for i := range pods {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			subResults[i] = sc.probe(pods[i], service.Spec.ClusterIP, nodePort, nodeIPs)
+		}(i)
+	}
+	wg.Wait()
+
+	brokenNodes := map[string]bool{}
+	for _, srs := range subResults {
+		for _, sr := range srs {
+			if sr.Status == checker.StatusFail {
+				brokenNodes[sr.Node] = true
+			}
+			result.AddSubResult(sr)
+		}
+	}
+
+	mode := sc.kubeProxyMode(ctx)
+	if len(brokenNodes) > 0 {
+		nodes := []string{}
+		for node := range brokenNodes {
+			nodes = append(nodes, node)
+		}
+		sort.Strings(nodes)
+		result.Failf("Service routing broken on %s (kube-proxy mode: %s)", strings.Join(nodes, ", "), mode)
+		return result
+	}
+	result.Message = fmt.Sprintf("kube-proxy mode: %s", mode)
+	return result
+}
+
+// probe connects from the pod to the ClusterIP of the debug service and to its
+// NodePort on every node. The ClusterIP result is reported for the node of the
+// pod, NodePort results as node pairs.
+func (sc ServiceChecker) probe(pod corev1.Pod, clusterIP string, nodePort int32, nodeIPs map[string]string) []checker.SubResult {
+	subResults := []checker.SubResult{}
+
+	sr := checker.SubResult{
+		Node:    pod.Spec.NodeName,
+		Status:  checker.StatusPass,
+		Message: fmt.Sprintf("ClusterIP %s:%d reachable", clusterIP, constant.IperfPort),
+	}
+	checkClusterIPCmd := fmt.Sprintf("nc -z -w 3 %s %d", clusterIP, constant.IperfPort)
+	if _, err := sc.RunCmdInPod(pod.Name, constant.DebugNamespace, checkClusterIPCmd); err != nil {
+		sr.Status = checker.StatusFail
+		sr.Message = fmt.Sprintf("ClusterIP %s:%d unreachable", clusterIP, constant.IperfPort)
+	}
+	subResults = append(subResults, sr)
+
+	nodes := []string{}
+	for node := range nodeIPs {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	for _, node := range nodes {
+		sr := checker.SubResult{
+			Node:   pod.Spec.NodeName,
+			Peer:   node,
+			Status: checker.StatusPass,
+		}
+		checkNodePortCmd := fmt.Sprintf("nc -z -w 3 %s %d", nodeIPs[node], nodePort)
+		if _, err := sc.RunCmdInPod(pod.Name, constant.DebugNamespace, checkNodePortCmd); err != nil {
+			sr.Status = checker.StatusFail
+			sr.Message = fmt.Sprintf("NodePort %s:%d unreachable", nodeIPs[node], nodePort)
+		}
+		subResults = append(subResults, sr)
+	}
+	return subResults
+}
+
+func (sc ServiceChecker) nodeInternalIPs(ctx context.Context) (map[string]string, error) {
+	nodeList := &corev1.NodeList{}
+	if err := sc.Client.List(ctx, nodeList, &client.ListOptions{}); err != nil {
+		return nil, fmt.Errorf("List nodes: %v", err)
+	}
+	nodeIPs := map[string]string{}
+	for _, node := range nodeList.Items {
+		for _, addr := range node.Status.Addresses {
+			if addr.Type == corev1.NodeInternalIP {
+				nodeIPs[node.Name] = addr.Address
+				break
+			}
+		}
+	}
+	return nodeIPs, nil
+}
+
+// kubeProxyMode reads the proxy mode from the kube-proxy config map. Without
+// kube-proxy, a Cilium deployment is assumed to replace it with eBPF.
+func (sc ServiceChecker) kubeProxyMode(ctx context.Context) string {
+	configMap := &corev1.ConfigMap{}
+	configMapLookupKey := types.NamespacedName{
+		Name:      "kube-proxy",
+		Namespace: "kube-system",
+	}
+	if err := sc.Client.Get(ctx, configMapLookupKey, configMap); err == nil {
+		for _, data := range configMap.Data {
+			for _, line := range strings.Split(data, "\n") {
+				fields := strings.SplitN(strings.TrimSpace(line), ":", 2)
+				if len(fields) != 2 || fields[0] != "mode" {
+					continue
+				}
+				mode := strings.Trim(strings.TrimSpace(fields[1]), `"'`)
+				if mode == "" {
+					// kube-proxy defaults to iptables on linux
+					return "iptables"
+				}
+				return mode
+			}
+		}
+		return "iptables"
+	}
+
+	ds := &appsv1.DaemonSet{}
+	ciliumLookupKey := types.NamespacedName{
+		Name:      "cilium",
+		Namespace: "kube-system",
+	}
+	if err := sc.Client.Get(ctx, ciliumLookupKey, ds); err == nil {
+		return "ebpf (cilium kube-proxy replacement)"
+	}
+	return "unknown"
+}
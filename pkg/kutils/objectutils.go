@@ -21,6 +21,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -144,10 +145,57 @@ func WaitDaemonSetReady(client client.Client, namespace string, name string) err
 		if ds.Status.NumberUnavailable > 0 || ds.Status.NumberAvailable == 0 {
 			return false, nil
 		}
+		// an updated daemonset is only ready once every pod runs the
+		// new template
+		if ds.Status.ObservedGeneration < ds.Generation || ds.Status.UpdatedNumberScheduled < ds.Status.DesiredNumberScheduled {
+			return false, nil
+		}
 		return true, nil
 	})
 }
 
+// WaitEndpointsReady waits until the service has at least count ready
+// endpoint addresses.
+func WaitEndpointsReady(client client.Client, namespace string, name string, count int) error {
+	return wait.Poll(constant.PollInterval, constant.PollTimeout, func() (done bool, err error) {
+		endpoints := &corev1.Endpoints{}
+		endpointsLookupKey := types.NamespacedName{
+			Name:      name,
+			Namespace: namespace,
+		}
+		if err := client.Get(context.TODO(), endpointsLookupKey, endpoints); err != nil {
+			return false, nil
+		}
+		ready := 0
+		for _, subset := range endpoints.Subsets {
+			ready += len(subset.Addresses)
+		}
+		return ready >= count, nil
+	})
+}
+
+// ListPods lists the pods of a debug daemonset by their app label, leaving out
+// those being deleted, such as the pods replaced by a daemonset update.
+func ListPods(ctx context.Context, c client.Client, namespace string, app string) ([]corev1.Pod, error) {
+	podList := &corev1.PodList{}
+	err := c.List(ctx, podList, &client.ListOptions{
+		Namespace: namespace,
+		LabelSelector: labels.SelectorFromSet(map[string]string{
+			"app": app,
+		}),
+	})
+	if err != nil {
+		return nil, err
+	}
+	pods := []corev1.Pod{}
+	for _, pod := range podList.Items {
+		if pod.DeletionTimestamp == nil {
+			pods = append(pods, pod)
+		}
+	}
+	return pods, nil
+}
+
 func NewNamespace(name string) *corev1.Namespace {
 	return &corev1.Namespace{
 		TypeMeta: metav1.TypeMeta{
@@ -16,16 +16,17 @@ package cni
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 
-	"github.com/enescakir/emoji"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/labels"
-	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/iomesh/debugtool/pkg/checker"
 	"github.com/iomesh/debugtool/pkg/constant"
+	"github.com/iomesh/debugtool/pkg/kutils"
+	"github.com/iomesh/debugtool/pkg/network"
 )
 
 type CNIChecker struct {
@@ -38,56 +39,129 @@ func NewCNIChecker() *CNIChecker {
 	}
 }
 
-func (cc CNIChecker) CheckConnectivity() error {
-	cc.SpinnerStart()
+func init() {
+	checker.Register(NewCNIChecker())
+}
 
-	podList := &corev1.PodList{}
-	err := cc.Client.List(context.TODO(), podList, &client.ListOptions{
-		Namespace: constant.DebugNamespace,
-		LabelSelector: labels.SelectorFromSet(map[string]string{
-			"app": constant.BasicCheckerLabel,
-		}),
-	})
-	if err != nil {
-		cc.SpinnerStop(emoji.CrossMark)
-		return fmt.Errorf("List basic checker pods: %v", err)
-	}
+func (cc CNIChecker) Name() string {
+	return constant.CNIConnectivityCheckName
+}
 
-	if len(podList.Items) < 2 {
-		cc.SpinnerStop(emoji.CrossMark)
-		return errors.New("Num of nodes less than 2")
-	}
-	clientPod := podList.Items[0]
-	for _, serverPod := range podList.Items {
-		checkConnectivityCmd := fmt.Sprintf("nc -zv %s 5201", serverPod.Status.PodIP)
-		_, err = cc.RunCmdInPod(clientPod.Name, constant.DebugNamespace, checkConnectivityCmd)
-		if err != nil {
-			cc.SpinnerStop(emoji.CrossMark)
-			return fmt.Errorf("Pod %s can't connect to Pod %s, check if CNI is configured correctly", clientPod.Name, serverPod.Name)
-		}
-	}
-	cc.SpinnerStop(emoji.CheckMarkButton)
-	return nil
+func (cc CNIChecker) Category() checker.Category {
+	return checker.CategoryNetwork
 }
 
-func (cc CNIChecker) GetBandwidth() string {
-	return ""
+func (cc CNIChecker) Dependencies() []string {
+	return nil
 }
 
-func (cc CNIChecker) GetLatencyMS() int {
-	return 0
+func (cc CNIChecker) Description() string {
+	return "Checking CNI connectivity"
 }
 
-func (cc CNIChecker) GetPacketLossRate() float32 {
-	return 0.1
+func (cc CNIChecker) Run(ctx context.Context) checker.Result {
+	result := checker.Result{
+		Status:      checker.StatusPass,
+		Remediation: "Check if CNI is configured correctly",
+	}
+
+	pods, err := kutils.ListPods(ctx, cc.Client, constant.DebugNamespace, constant.BasicCheckerLabel)
+	if err != nil {
+		result.Failf("List basic checker pods: %v", err)
+		return result
+	}
+
+	if len(pods) < 2 {
+		result.Failf("Num of nodes less than 2")
+		return result
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].Spec.NodeName < pods[j].Spec.NodeName
+	})
+
+	// every pod probes all addresses of the other pods in a single
+	// command, the pods run their probes concurrently
+	reachable := make([]map[string]bool, len(pods))
+	errs := make([]error, len(pods))
+	var wg sync.WaitGroup
+	for i := range pods {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			reachable[i], errs[i] = cc.probe(pods[i], pods)
+		}(i)
+	}
+	wg.Wait()
+
+	failed, total := 0, 0
+	for _, family := range network.PodFamilies(pods) {
+		for i, clientPod := range pods {
+			for j, serverPod := range pods {
+				if i == j {
+					continue
+				}
+				sr := cc.pairSubResult(family, clientPod, serverPod, reachable, errs, i, j)
+				if sr.Status == checker.StatusFail {
+					failed++
+				}
+				total++
+				result.AddSubResult(sr)
+			}
+		}
+	}
+	if failed > 0 {
+		result.Failf("%d of %d pod pairs can't connect over the pod network", failed, total)
+	}
+	return result
 }
 
-func (cc CNIChecker) SpinnerStart() {
-	cc.Spinner.Suffix = " Checking CNI connectivity"
-	cc.Spinner.Start()
+// pairSubResult reports whether the i-th pod reached the address of the j-th
+// pod in the given family.
+func (cc CNIChecker) pairSubResult(family network.Family, clientPod, serverPod corev1.Pod, reachable []map[string]bool, errs []error, i, j int) checker.SubResult {
+	sr := checker.SubResult{
+		Node:   clientPod.Spec.NodeName,
+		Peer:   serverPod.Spec.NodeName,
+		Family: string(family),
+		Status: checker.StatusPass,
+	}
+	serverIP := network.PodIP(serverPod, family)
+	switch {
+	case errs[i] != nil:
+		sr.Status = checker.StatusFail
+		sr.Message = errs[i].Error()
+	case serverIP == "":
+		sr.Status = checker.StatusFail
+		sr.Message = fmt.Sprintf("Pod %s has no %s address", serverPod.Name, family)
+	case !reachable[i][serverIP]:
+		sr.Status = checker.StatusFail
+		sr.Message = fmt.Sprintf("Pod %s can't connect to Pod %s (%s)", clientPod.Name, serverPod.Name, serverIP)
+		if clientIP := network.PodIP(clientPod, family); errs[j] == nil && reachable[j][clientIP] {
+			sr.Message += ", only the opposite direction works"
+		}
+	}
+	return sr
 }
 
-func (cc CNIChecker) SpinnerStop(emoji emoji.Emoji) {
-	cc.Spinner.FinalMSG = fmt.Sprintf("%v Checking CNI connectivity\n", emoji)
-	cc.Spinner.Stop()
+// probe checks from clientPod whether the iperf3 port of every address of
+// every pod is reachable, and returns the reachable addresses.
+func (cc CNIChecker) probe(clientPod corev1.Pod, pods []corev1.Pod) (map[string]bool, error) {
+	probeCmd := ""
+	for _, serverPod := range pods {
+		for _, ip := range network.PodIPs(serverPod) {
+			probeCmd += fmt.Sprintf("if nc -z -w 3 %[1]s %[2]d; then echo %[1]s ok; else echo %[1]s fail; fi; ", ip, constant.IperfPort)
+		}
+	}
+	output, err := cc.RunCmdInPod(clientPod.Name, constant.DebugNamespace, probeCmd)
+	if err != nil {
+		return nil, fmt.Errorf("Run connectivity probe in pod %s: %v", clientPod.Name, err)
+	}
+
+	reachable := map[string]bool{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[1] == "ok" {
+			reachable[fields[0]] = true
+		}
+	}
+	return reachable, nil
 }
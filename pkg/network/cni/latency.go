@@ -0,0 +1,114 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cni
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+	"github.com/iomesh/debugtool/pkg/config"
+	"github.com/iomesh/debugtool/pkg/constant"
+	"github.com/iomesh/debugtool/pkg/kutils"
+	"github.com/iomesh/debugtool/pkg/network"
+)
+
+type LatencyChecker struct {
+	checker.Checker
+}
+
+func NewLatencyChecker() *LatencyChecker {
+	return &LatencyChecker{
+		Checker: checker.Newchecker("CNILatencyChecker"),
+	}
+}
+
+func init() {
+	checker.Register(NewLatencyChecker())
+}
+
+func (lc LatencyChecker) Name() string {
+	return constant.CNILatencyCheckName
+}
+
+func (lc LatencyChecker) Category() checker.Category {
+	return checker.CategoryNetwork
+}
+
+func (lc LatencyChecker) Dependencies() []string {
+	return []string{constant.CNIConnectivityCheckName}
+}
+
+func (lc LatencyChecker) Description() string {
+	return "Measuring CNI latency"
+}
+
+func (lc LatencyChecker) Run(ctx context.Context) checker.Result {
+	result := checker.Result{
+		Status:      checker.StatusPass,
+		Remediation: "Check the CNI overlay and the node network, pod to pod latency adds to every volume access",
+	}
+
+	pods, err := kutils.ListPods(ctx, lc.Client, constant.DebugNamespace, constant.BasicCheckerLabel)
+	if err != nil {
+		result.Failf("List basic checker pods: %v", err)
+		return result
+	}
+	if len(pods) < 2 {
+		result.Failf("Num of nodes less than 2")
+		return result
+	}
+
+	for _, family := range network.PodFamilies(pods) {
+		subResults := network.MeasurePairs(len(pods), func(pair network.Pair) checker.SubResult {
+			return lc.measure(pods[pair.Client], pods[pair.Server], family)
+		})
+		for _, sr := range subResults {
+			result.AddSubResult(sr)
+		}
+	}
+
+	if failed := result.Count(checker.StatusFail); failed > 0 {
+		result.Failf("%d pod pairs can't ping each other", failed)
+	} else if slow := result.Count(checker.StatusWarn); slow > 0 {
+		result.Warnf("%d pod pairs exceed %.2fms average round trip time", slow, config.Get().MaxLatencyMS)
+	}
+	return result
+}
+
+// measure pings the address of serverPod in the given family from clientPod.
+func (lc LatencyChecker) measure(clientPod, serverPod corev1.Pod, family network.Family) checker.SubResult {
+	sr := checker.SubResult{
+		Node:   clientPod.Spec.NodeName,
+		Peer:   serverPod.Spec.NodeName,
+		Family: string(family),
+		Status: checker.StatusPass,
+	}
+	serverIP := network.PodIP(serverPod, family)
+	if serverIP == "" {
+		sr.Status = checker.StatusFail
+		sr.Message = fmt.Sprintf("Pod %s has no %s address", serverPod.Name, family)
+		return sr
+	}
+	output, err := lc.RunCmdInPod(clientPod.Name, constant.DebugNamespace, network.PingCmd("", serverIP))
+	if err != nil {
+		sr.Status = checker.StatusFail
+		sr.Message = fmt.Sprintf("Run ping in pod %s: %v", clientPod.Name, err)
+		return sr
+	}
+	return network.LatencySubResult(sr, output, config.Get().MaxLatencyMS)
+}
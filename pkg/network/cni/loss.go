@@ -0,0 +1,115 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cni
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+	"github.com/iomesh/debugtool/pkg/config"
+	"github.com/iomesh/debugtool/pkg/constant"
+	"github.com/iomesh/debugtool/pkg/kutils"
+	"github.com/iomesh/debugtool/pkg/network"
+)
+
+type LossChecker struct {
+	checker.Checker
+}
+
+func NewLossChecker() *LossChecker {
+	return &LossChecker{
+		Checker: checker.Newchecker("CNILossChecker"),
+	}
+}
+
+func init() {
+	checker.Register(NewLossChecker())
+}
+
+func (lsc LossChecker) Name() string {
+	return constant.CNILossCheckName
+}
+
+func (lsc LossChecker) Category() checker.Category {
+	return checker.CategoryNetwork
+}
+
+func (lsc LossChecker) Dependencies() []string {
+	return []string{constant.CNIConnectivityCheckName}
+}
+
+func (lsc LossChecker) Description() string {
+	return "Measuring CNI packet loss"
+}
+
+func (lsc LossChecker) Run(ctx context.Context) checker.Result {
+	result := checker.Result{
+		Status:      checker.StatusPass,
+		Remediation: "Check the CNI overlay MTU and the node network, lost packets stall volume access under load",
+	}
+
+	pods, err := kutils.ListPods(ctx, lsc.Client, constant.DebugNamespace, constant.BasicCheckerLabel)
+	if err != nil {
+		result.Failf("List basic checker pods: %v", err)
+		return result
+	}
+	if len(pods) < 2 {
+		result.Failf("Num of nodes less than 2")
+		return result
+	}
+
+	for _, family := range network.PodFamilies(pods) {
+		subResults := network.MeasurePairs(len(pods), func(pair network.Pair) checker.SubResult {
+			return lsc.measure(pods[pair.Client], pods[pair.Server], family)
+		})
+		for _, sr := range subResults {
+			result.AddSubResult(sr)
+		}
+	}
+
+	if failed := result.Count(checker.StatusFail); failed > 0 {
+		result.Failf("%d pod pairs lose too many packets or can't be measured", failed)
+	} else if lossy := result.Count(checker.StatusWarn); lossy > 0 {
+		result.Warnf("%d pod pairs lose more than %.2f%% packets", lossy, config.Get().WarnLossPercent)
+	}
+	return result
+}
+
+// measure sends UDP traffic from clientPod to the iperf3 server of serverPod
+// over the given family.
+func (lsc LossChecker) measure(clientPod, serverPod corev1.Pod, family network.Family) checker.SubResult {
+	sr := checker.SubResult{
+		Node:   clientPod.Spec.NodeName,
+		Peer:   serverPod.Spec.NodeName,
+		Family: string(family),
+		Status: checker.StatusPass,
+	}
+	serverIP := network.PodIP(serverPod, family)
+	if serverIP == "" {
+		sr.Status = checker.StatusFail
+		sr.Message = fmt.Sprintf("Pod %s has no %s address", serverPod.Name, family)
+		return sr
+	}
+	output, err := lsc.RunCmdInPod(clientPod.Name, constant.DebugNamespace, network.UDPLossCmd("", serverIP, config.Get().LossRate))
+	if err != nil {
+		sr.Status = checker.StatusFail
+		sr.Message = fmt.Sprintf("Run iperf3 in pod %s: %v", clientPod.Name, err)
+		return sr
+	}
+	return network.LossSubResult(sr, output)
+}
@@ -0,0 +1,231 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+	"github.com/iomesh/debugtool/pkg/constant"
+	"github.com/iomesh/debugtool/pkg/fixture"
+	"github.com/iomesh/debugtool/pkg/kutils"
+	"github.com/iomesh/debugtool/pkg/network"
+)
+
+// virtualInterfacePrefixes are interfaces created by container runtimes,
+// CNI plugins and kube-proxy, which never carry the data network.
+var virtualInterfacePrefixes = []string{
+	"lo", "docker", "cni", "flannel", "cali", "veth", "tunl", "vxlan",
+	"kube-ipvs", "cilium", "weave", "virbr", "genev", "nodelocaldns",
+}
+
+// Address is a global address configured on an interface of a node.
+type Address struct {
+	Node      string
+	Interface string
+	IP        net.IP
+	Network   *net.IPNet
+}
+
+// Candidate is a network every node has an address in.
+type Candidate struct {
+	CIDR string
+	// Interfaces and Addresses are indexed by node name.
+	Interfaces map[string]string
+	Addresses  map[string]string
+}
+
+type Discoverer struct {
+	checker.Checker
+}
+
+func NewDiscoverer() *Discoverer {
+	return &Discoverer{
+		Checker: checker.Newchecker("Discoverer"),
+	}
+}
+
+// Discover inspects the interfaces of every node through the hostnetwork
+// checker daemonset, whose pods see the addresses of their node without being
+// privileged, and returns the candidate data networks: the networks shared by
+// all nodes, except the network of the Kubernetes node addresses.
+func (d Discoverer) Discover(ctx context.Context) ([]Candidate, error) {
+	if err := fixture.GetInstance().EnsureHostNetworkDsDeployed(ctx); err != nil {
+		return nil, err
+	}
+	pods, err := kutils.ListPods(ctx, d.Client, constant.DebugNamespace, constant.HostNetworkCheckerLabel)
+	if err != nil {
+		return nil, fmt.Errorf("List hostnetwork checker pods: %v", err)
+	}
+	if len(pods) == 0 {
+		return nil, errors.New("No hostnetwork checker pod running")
+	}
+	nodeIPs, err := d.nodeIPs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := []Address{}
+	for _, pod := range pods {
+		output, err := d.RunCmdInPod(pod.Name, constant.DebugNamespace, "ip -o addr show")
+		if err != nil {
+			return nil, fmt.Errorf("List addresses of node %s: %v", pod.Spec.NodeName, err)
+		}
+		addresses = append(addresses, ParseAddresses(pod.Spec.NodeName, output)...)
+	}
+	return Candidates(addresses, len(pods), nodeIPs), nil
+}
+
+func (d Discoverer) nodeIPs(ctx context.Context) ([]net.IP, error) {
+	nodeList := &corev1.NodeList{}
+	if err := d.Client.List(ctx, nodeList, &client.ListOptions{}); err != nil {
+		return nil, fmt.Errorf("List nodes: %v", err)
+	}
+	ips := []net.IP{}
+	for _, node := range nodeList.Items {
+		for _, addr := range node.Status.Addresses {
+			if addr.Type != corev1.NodeInternalIP && addr.Type != corev1.NodeExternalIP {
+				continue
+			}
+			if ip := net.ParseIP(addr.Address); ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return ips, nil
+}
+
+// ParseAddresses parses the output of `ip -o addr show` and returns the
+// global addresses of physical interfaces, bonds and VLANs.
+func ParseAddresses(node, output string) []Address {
+	addresses := []Address{}
+	for _, line := range strings.Split(output, "\n") {
+		// 2: eth1    inet 192.168.1.10/24 brd 192.168.1.255 scope global eth1\ ...
+		fields := strings.Fields(line)
+		if len(fields) < 4 || (fields[2] != "inet" && fields[2] != "inet6") {
+			continue
+		}
+		if !strings.Contains(line, "scope global") {
+			continue
+		}
+		iface := strings.SplitN(fields[1], "@", 2)[0]
+		if isVirtualInterface(iface) {
+			continue
+		}
+		ip, network, err := net.ParseCIDR(fields[3])
+		if err != nil {
+			continue
+		}
+		addresses = append(addresses, Address{
+			Node:      node,
+			Interface: iface,
+			IP:        ip,
+			Network:   network,
+		})
+	}
+	return addresses
+}
+
+// Candidates returns the networks in which each of the nodeCount nodes has an
+// address, leaving out the networks holding a Kubernetes node address.
+func Candidates(addresses []Address, nodeCount int, nodeIPs []net.IP) []Candidate {
+	byCIDR := map[string]*Candidate{}
+	networks := map[string]*net.IPNet{}
+	for _, addr := range addresses {
+		cidr := addr.Network.String()
+		candidate, ok := byCIDR[cidr]
+		if !ok {
+			candidate = &Candidate{
+				CIDR:       cidr,
+				Interfaces: map[string]string{},
+				Addresses:  map[string]string{},
+			}
+			byCIDR[cidr] = candidate
+			networks[cidr] = addr.Network
+		}
+		candidate.Interfaces[addr.Node] = addr.Interface
+		candidate.Addresses[addr.Node] = addr.IP.String()
+	}
+
+	candidates := []Candidate{}
+	for cidr, candidate := range byCIDR {
+		if len(candidate.Addresses) < nodeCount || containsAny(networks[cidr], nodeIPs) {
+			continue
+		}
+		candidates = append(candidates, *candidate)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].CIDR < candidates[j].CIDR
+	})
+	return candidates
+}
+
+// DualStack tells whether the candidates are an IPv4 and an IPv6 network on
+// the same interface of every node, and if so joins them into the data CIDR
+// of a dual-stack data network.
+func DualStack(candidates []Candidate) (string, bool) {
+	if len(candidates) != 2 {
+		return "", false
+	}
+	first, second := candidates[0], candidates[1]
+	if network.FamilyOf(first.Addresses[anyNode(first)]) == network.FamilyOf(second.Addresses[anyNode(second)]) {
+		return "", false
+	}
+	if len(first.Interfaces) != len(second.Interfaces) {
+		return "", false
+	}
+	for node, iface := range first.Interfaces {
+		if second.Interfaces[node] != iface {
+			return "", false
+		}
+	}
+	if network.FamilyOf(first.Addresses[anyNode(first)]) == network.IPv6 {
+		first, second = second, first
+	}
+	return first.CIDR + "," + second.CIDR, true
+}
+
+func anyNode(candidate Candidate) string {
+	for node := range candidate.Addresses {
+		return node
+	}
+	return ""
+}
+
+func containsAny(network *net.IPNet, ips []net.IP) bool {
+	for _, ip := range ips {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func isVirtualInterface(iface string) bool {
+	for _, prefix := range virtualInterfacePrefixes {
+		if strings.HasPrefix(iface, prefix) {
+			return true
+		}
+	}
+	return false
+}
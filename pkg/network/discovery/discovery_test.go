@@ -0,0 +1,203 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package discovery
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+const ipAddrOutput = `1: lo    inet 127.0.0.1/8 scope host lo\       valid_lft forever preferred_lft forever
+1: lo    inet6 ::1/128 scope host \       valid_lft forever preferred_lft forever
+2: eth0    inet 10.0.0.11/24 brd 10.0.0.255 scope global eth0\       valid_lft forever preferred_lft forever
+2: eth0    inet6 fe80::1/64 scope link \       valid_lft forever preferred_lft forever
+3: bond0    inet 192.168.10.11/24 brd 192.168.10.255 scope global bond0\       valid_lft forever preferred_lft forever
+3: bond0    inet6 fd00:10::11/64 scope global \       valid_lft forever preferred_lft forever
+4: eth1.100@eth1    inet 172.16.100.11/24 brd 172.16.100.255 scope global eth1.100\       valid_lft forever preferred_lft forever
+5: docker0    inet 172.17.0.1/16 brd 172.17.255.255 scope global docker0\       valid_lft forever preferred_lft forever
+6: cali1234@if3    inet 10.244.0.1/32 scope global cali1234\       valid_lft forever preferred_lft forever
+7: flannel.1    inet 10.244.0.0/32 scope global flannel.1\       valid_lft forever preferred_lft forever
+8: kube-ipvs0    inet 10.96.0.1/32 scope global kube-ipvs0\       valid_lft forever preferred_lft forever
+`
+
+func mustParseCIDR(t *testing.T, cidr string) (net.IP, *net.IPNet) {
+	ip, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ip, network
+}
+
+func TestParseAddresses(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []string
+	}{
+		{name: "empty", output: "", want: []string{}},
+		{name: "garbage", output: "Device \"eth9\" does not exist.\n1: eth0 inet\n", want: []string{}},
+		{name: "malformed address", output: "2: eth0    inet 10.0.0.300/24 scope global eth0\n", want: []string{}},
+		{
+			name:   "physical, bond and vlan only",
+			output: ipAddrOutput,
+			want: []string{
+				"eth0 10.0.0.11 10.0.0.0/24",
+				"bond0 192.168.10.11 192.168.10.0/24",
+				"bond0 fd00:10::11 fd00:10::/64",
+				"eth1.100 172.16.100.11 172.16.100.0/24",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, addr := range ParseAddresses("node1", tt.output) {
+				if addr.Node != "node1" {
+					t.Errorf("address %v of node %q", addr.IP, addr.Node)
+				}
+				got = append(got, addr.Interface+" "+addr.IP.String()+" "+addr.Network.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseAddresses() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCandidates(t *testing.T) {
+	address := func(node, iface, cidr string) Address {
+		ip, network := mustParseCIDR(t, cidr)
+		return Address{Node: node, Interface: iface, IP: ip, Network: network}
+	}
+	// eth0 holds the node addresses, bond0 the storage network in both
+	// families, eth2 is only on two of the three nodes
+	addresses := []Address{
+		address("node1", "eth0", "10.0.0.11/24"),
+		address("node2", "eth0", "10.0.0.12/24"),
+		address("node3", "eth0", "10.0.0.13/24"),
+		address("node1", "bond0", "192.168.10.11/24"),
+		address("node2", "bond0", "192.168.10.12/24"),
+		address("node3", "bond0", "192.168.10.13/24"),
+		address("node1", "bond0", "fd00:10::11/64"),
+		address("node2", "bond0", "fd00:10::12/64"),
+		address("node3", "bond0", "fd00:10::13/64"),
+		address("node1", "eth2", "172.16.0.11/24"),
+		address("node2", "eth2", "172.16.0.12/24"),
+	}
+	nodeIPs := []net.IP{net.ParseIP("10.0.0.11"), net.ParseIP("10.0.0.12"), net.ParseIP("10.0.0.13")}
+
+	tests := []struct {
+		name      string
+		addresses []Address
+		nodeCount int
+		nodeIPs   []net.IP
+		want      []string
+	}{
+		{name: "empty cluster", want: []string{}},
+		{name: "no address", nodeCount: 3, nodeIPs: nodeIPs, want: []string{}},
+		{
+			name:      "shared networks except the node network",
+			addresses: addresses,
+			nodeCount: 3,
+			nodeIPs:   nodeIPs,
+			want:      []string{"192.168.10.0/24", "fd00:10::/64"},
+		},
+		{
+			name:      "node network unknown",
+			addresses: addresses,
+			nodeCount: 3,
+			want:      []string{"10.0.0.0/24", "192.168.10.0/24", "fd00:10::/64"},
+		},
+		{
+			name:      "IPv6 node network",
+			addresses: addresses,
+			nodeCount: 3,
+			nodeIPs:   []net.IP{net.ParseIP("fd00:10::11")},
+			want:      []string{"10.0.0.0/24", "192.168.10.0/24"},
+		},
+		{
+			name:      "two nodes",
+			addresses: addresses,
+			nodeCount: 2,
+			nodeIPs:   nodeIPs,
+			want:      []string{"172.16.0.0/24", "192.168.10.0/24", "fd00:10::/64"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, candidate := range Candidates(tt.addresses, tt.nodeCount, tt.nodeIPs) {
+				got = append(got, candidate.CIDR)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Candidates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	candidates := Candidates(addresses, 3, nodeIPs)
+	if iface := candidates[0].Interfaces["node2"]; iface != "bond0" {
+		t.Errorf("interface of node2 = %q, want bond0", iface)
+	}
+	if addr := candidates[1].Addresses["node3"]; addr != "fd00:10::13" {
+		t.Errorf("address of node3 = %q, want fd00:10::13", addr)
+	}
+}
+
+func TestDualStack(t *testing.T) {
+	candidate := func(cidr string, interfaces, addresses map[string]string) Candidate {
+		return Candidate{CIDR: cidr, Interfaces: interfaces, Addresses: addresses}
+	}
+	v4 := candidate("192.168.10.0/24",
+		map[string]string{"node1": "bond0", "node2": "bond0"},
+		map[string]string{"node1": "192.168.10.11", "node2": "192.168.10.12"})
+	v6 := candidate("fd00:10::/64",
+		map[string]string{"node1": "bond0", "node2": "bond0"},
+		map[string]string{"node1": "fd00:10::11", "node2": "fd00:10::12"})
+	v6OtherInterface := candidate("fd00:20::/64",
+		map[string]string{"node1": "bond0", "node2": "eth2"},
+		map[string]string{"node1": "fd00:20::11", "node2": "fd00:20::12"})
+	v6OneNode := candidate("fd00:30::/64",
+		map[string]string{"node1": "bond0"},
+		map[string]string{"node1": "fd00:30::11"})
+	otherV4 := candidate("172.16.0.0/24",
+		map[string]string{"node1": "bond0", "node2": "bond0"},
+		map[string]string{"node1": "172.16.0.11", "node2": "172.16.0.12"})
+
+	tests := []struct {
+		name       string
+		candidates []Candidate
+		want       string
+		ok         bool
+	}{
+		{name: "no candidate"},
+		{name: "single network", candidates: []Candidate{v4}},
+		{name: "IPv4 first", candidates: []Candidate{v4, v6}, want: "192.168.10.0/24,fd00:10::/64", ok: true},
+		{name: "IPv6 first", candidates: []Candidate{v6, v4}, want: "192.168.10.0/24,fd00:10::/64", ok: true},
+		{name: "same family", candidates: []Candidate{v4, otherV4}},
+		{name: "different interface", candidates: []Candidate{v4, v6OtherInterface}},
+		{name: "different nodes", candidates: []Candidate{v4, v6OneNode}},
+		{name: "three networks", candidates: []Candidate{v4, v6, otherV4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := DualStack(tt.candidates)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("DualStack() = %q, %v, want %q, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package network
+
+import (
+	"net"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Family is the address family of an IP address, used to label the results
+// measured separately over IPv4 and IPv6.
+type Family string
+
+const (
+	IPv4 Family = "IPv4"
+	IPv6 Family = "IPv6"
+)
+
+// FamilyOf returns the address family of ip, which may be given in any of the
+// forms accepted by net.ParseIP. Unparsable addresses are reported as IPv4.
+func FamilyOf(ip string) Family {
+	parsed := net.ParseIP(ip)
+	if parsed != nil && parsed.To4() == nil {
+		return IPv6
+	}
+	return IPv4
+}
+
+// ICMPHeaderSize is the size of the IP and ICMP headers added to the payload
+// of a ping.
+func (f Family) ICMPHeaderSize() int {
+	if f == IPv6 {
+		return 48
+	}
+	return 28
+}
+
+// PodIPs returns the addresses of a pod, one per address family on a
+// dual-stack cluster.
+func PodIPs(pod corev1.Pod) []string {
+	ips := []string{}
+	for _, podIP := range pod.Status.PodIPs {
+		ips = append(ips, podIP.IP)
+	}
+	if len(ips) == 0 && pod.Status.PodIP != "" {
+		ips = append(ips, pod.Status.PodIP)
+	}
+	return ips
+}
+
+// PodIP returns the address of the pod in the given family, or an empty
+// string if it has none.
+func PodIP(pod corev1.Pod, family Family) string {
+	for _, ip := range PodIPs(pod) {
+		if FamilyOf(ip) == family {
+			return ip
+		}
+	}
+	return ""
+}
+
+// PodFamilies returns the address families any of the pods has an address
+// in, IPv4 first.
+func PodFamilies(pods []corev1.Pod) []Family {
+	found := map[Family]bool{}
+	for _, pod := range pods {
+		for _, ip := range PodIPs(pod) {
+			found[FamilyOf(ip)] = true
+		}
+	}
+	families := []Family{}
+	for _, family := range []Family{IPv4, IPv6} {
+		if found[family] {
+			families = append(families, family)
+		}
+	}
+	return families
+}
@@ -14,15 +14,128 @@ limitations under the License.
 */
 package hostnetwork
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+	"github.com/iomesh/debugtool/pkg/network"
+)
 
 type CheckResult struct {
-	SourceIP      string
-	DestinationIP string
-	BandwidthMB   float32
-	LatencyMS     float32
+	SourceNode      string  `json:"sourceNode"`
+	DestinationNode string  `json:"destinationNode"`
+	SourceIP        string  `json:"sourceIP"`
+	DestinationIP   string  `json:"destinationIP"`
+	BandwidthMB     float32 `json:"bandwidthMB"`
+
+	Family network.Family `json:"family"`
+
+	Retransmits int `json:"retransmits"`
+	// SenderCPUPercent and ReceiverCPUPercent are the total CPU
+	// utilization of the iperf3 client and server during the test.
+	SenderCPUPercent   float64          `json:"senderCPUPercent"`
+	ReceiverCPUPercent float64          `json:"receiverCPUPercent"`
+	Intervals          []IntervalSample `json:"intervals,omitempty"`
+
+	Err error `json:"-"`
+}
+
+// IntervalSample is the throughput of one reporting interval of iperf3.
+type IntervalSample struct {
+	StartSeconds float64 `json:"startSeconds"`
+	EndSeconds   float64 `json:"endSeconds"`
+	BandwidthMB  float32 `json:"bandwidthMB"`
+	Retransmits  int     `json:"retransmits"`
+}
+
+func (cr *CheckResult) setIperfReport(report *network.IperfReport) {
+	cr.BandwidthMB = float32(network.BitsToMB(report.End.SumReceived.BitsPerSecond))
+	cr.Retransmits = report.End.SumSent.Retransmits
+	cr.SenderCPUPercent = report.End.CPUUtilizationPercent.HostTotal
+	cr.ReceiverCPUPercent = report.End.CPUUtilizationPercent.RemoteTotal
+	for _, interval := range report.Intervals {
+		cr.Intervals = append(cr.Intervals, IntervalSample{
+			StartSeconds: interval.Sum.Start,
+			EndSeconds:   interval.Sum.End,
+			BandwidthMB:  float32(network.BitsToMB(interval.Sum.BitsPerSecond)),
+			Retransmits:  interval.Sum.Retransmits,
+		})
+	}
+}
+
+func (cr CheckResult) SubResult() checker.SubResult {
+	sr := checker.SubResult{
+		Node:   cr.SourceNode,
+		Peer:   cr.DestinationNode,
+		Family: string(cr.Family),
+		Status: checker.StatusPass,
+	}
+	if cr.Err != nil {
+		sr.Status = checker.StatusFail
+		sr.Message = cr.Err.Error()
+		return sr
+	}
+	sr.Message = fmt.Sprintf("%s -> %s", cr.SourceIP, cr.DestinationIP)
+	sr.Measurements = []checker.Measurement{
+		{Name: "bandwidth", Value: float64(cr.BandwidthMB), Unit: "MB/s"},
+		{Name: "retransmits", Value: float64(cr.Retransmits)},
+	}
+	sr.Details = cr
+	return sr
+}
+
+// sortCheckResults orders the results by family, source and then
+// destination node.
+func sortCheckResults(results []CheckResult) {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Family != results[j].Family {
+			return results[i].Family < results[j].Family
+		}
+		if results[i].SourceNode != results[j].SourceNode {
+			return results[i].SourceNode < results[j].SourceNode
+		}
+		return results[i].DestinationNode < results[j].DestinationNode
+	})
 }
 
-func (cr CheckResult) Println() {
-	fmt.Printf("    %s <--> %s (%.2fMB/s)  \n", cr.SourceIP, cr.DestinationIP, cr.BandwidthMB)
+// nodeSummary summarizes the bandwidth of every test of the family the node
+// took part in, either as client or as server.
+func nodeSummary(node string, family network.Family, results []CheckResult) checker.SubResult {
+	sr := checker.SubResult{
+		Node:   node,
+		Family: string(family),
+		Status: checker.StatusPass,
+	}
+	var min, max, sum float64
+	count := 0
+	for _, cr := range results {
+		if cr.Family != family || (cr.SourceNode != node && cr.DestinationNode != node) {
+			continue
+		}
+		if cr.Err != nil {
+			sr.Status = checker.StatusFail
+			continue
+		}
+		bandwidth := float64(cr.BandwidthMB)
+		if count == 0 || bandwidth < min {
+			min = bandwidth
+		}
+		if count == 0 || bandwidth > max {
+			max = bandwidth
+		}
+		sum += bandwidth
+		count++
+	}
+	if count == 0 {
+		sr.Status = checker.StatusFail
+		sr.Message = "No successful bandwidth measurement"
+		return sr
+	}
+	sr.Measurements = []checker.Measurement{
+		{Name: "min", Value: min, Unit: "MB/s"},
+		{Name: "avg", Value: sum / float64(count), Unit: "MB/s"},
+		{Name: "max", Value: max, Unit: "MB/s"},
+	}
+	return sr
 }
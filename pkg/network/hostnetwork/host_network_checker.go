@@ -18,21 +18,18 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"net"
-	"os"
-	"strconv"
+	"sort"
 	"strings"
+	"sync"
 
-	"github.com/enescakir/emoji"
-	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/labels"
-	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/iomesh/debugtool/pkg/checker"
+	"github.com/iomesh/debugtool/pkg/config"
 	"github.com/iomesh/debugtool/pkg/constant"
+	"github.com/iomesh/debugtool/pkg/fixture"
 	"github.com/iomesh/debugtool/pkg/kutils"
+	"github.com/iomesh/debugtool/pkg/network"
 )
 
 type HostNetworkChecker struct {
@@ -46,120 +43,192 @@ func NewHostNetworkChecker() *HostNetworkChecker {
 	return checker
 }
 
-func (hc HostNetworkChecker) GetBandwidth() error {
-	hc.SpinnerStart()
+func init() {
+	checker.Register(NewHostNetworkChecker())
+}
 
-	ds, err := hc.HostNetworkCheckerDaemonSet(constant.DebugNamespace, constant.HostNetworkCheckerDSName)
-	if err != nil {
-		hc.SpinnerStop(emoji.CrossMark)
-		return fmt.Errorf("create basic checker daemonset fail: %v", err)
-	}
+func (hc HostNetworkChecker) Name() string {
+	return constant.HostNetworkBandwidthCheckName
+}
 
-	if err := hc.Client.Create(context.TODO(), ds); err != nil {
-		hc.SpinnerStop(emoji.CrossMark)
-		return fmt.Errorf("create HostNetworkCheckerDaemonSet: %v", err)
-	}
-	if err := kutils.WaitDaemonSetReady(hc.Client, constant.DebugNamespace, constant.HostNetworkCheckerDSName); err != nil {
-		hc.SpinnerStop(emoji.CrossMark)
-		return fmt.Errorf("HostNetworkCheckerDaemonSet create: %v", err)
+func (hc HostNetworkChecker) Category() checker.Category {
+	return checker.CategoryNetwork
+}
+
+func (hc HostNetworkChecker) Dependencies() []string {
+	return nil
+}
+
+func (hc HostNetworkChecker) Description() string {
+	return "Measuring hostnetwork bandwidth"
+}
+
+func (hc HostNetworkChecker) Run(ctx context.Context) checker.Result {
+	result := checker.Result{
+		Status:      checker.StatusPass,
+		Remediation: "Check if HostNetwork is configured correctly and IOMESH_DATA_CIDR matches the storage network",
 	}
-	podList := &corev1.PodList{}
-	err = hc.Client.List(context.TODO(), podList, &client.ListOptions{
-		Namespace: constant.DebugNamespace,
-		LabelSelector: labels.SelectorFromSet(map[string]string{
-			"app": constant.HostNetworkCheckerLabel,
-		}),
-	})
+
+	groups, err := getFamilyEndpoints(ctx, hc.Checker, &result)
 	if err != nil {
-		hc.SpinnerStop(emoji.CrossMark)
-		return fmt.Errorf("List debugtool's: %v", err)
+		result.Failf("%v", err)
+		return result
 	}
 
-	if len(podList.Items) < 2 {
-		hc.SpinnerStop(emoji.CrossMark)
-		return errors.New("Num of nodes less than 2")
+	// the families are measured one after the other so their tests don't
+	// compete for the same links
+	checkResults := []CheckResult{}
+	for _, endpoints := range groups {
+		var lock sync.Mutex
+		network.RunScheduled(len(endpoints), func(pair network.Pair) {
+			cr := hc.measure(endpoints[pair.Client], endpoints[pair.Server])
+			lock.Lock()
+			checkResults = append(checkResults, cr)
+			lock.Unlock()
+		})
 	}
-	results := []CheckResult{}
-	for iperfClientIdx := 0; iperfClientIdx < len(podList.Items)-1; iperfClientIdx++ {
-		for iperfServerIdx := iperfClientIdx + 1; iperfServerIdx < len(podList.Items); iperfServerIdx++ {
-			clientPod := podList.Items[iperfClientIdx]
-			serverPod := podList.Items[iperfServerIdx]
-
-			getClientIperfListenIPCmd := "cat /opt/iperf_bind_addr"
-			output, err := hc.RunCmdInPod(clientPod.Name, constant.DebugNamespace, getClientIperfListenIPCmd)
-			if err != nil {
-				hc.SpinnerStop(emoji.CrossMark)
-				return fmt.Errorf("Get pod %s iperf ip: %v", clientPod.Name, err)
-			}
-			clientIperfIP := strings.TrimSpace(output)
+	sortCheckResults(checkResults)
+	recordBandwidth(checkResults)
 
-			getServerIperfListenIPCmd := "cat /opt/iperf_bind_addr"
-			output, err = hc.RunCmdInPod(serverPod.Name, constant.DebugNamespace, getServerIperfListenIPCmd)
-			if err != nil {
-				hc.SpinnerStop(emoji.CrossMark)
-				return fmt.Errorf("Get pod %s iperf ip: %v", serverPod.Name, err)
-			}
-			serverIperfIP := strings.TrimSpace(output)
-
-			doIperfCmd := fmt.Sprintf("iperf3 -c %s -t 5 | grep sender | awk '{print $7/8*1024}'", serverIperfIP)
-			hc.Log.V(5).Info(doIperfCmd)
-			output, err = hc.RunCmdInPod(clientPod.Name, constant.DebugNamespace, doIperfCmd)
-			if err != nil {
-				hc.SpinnerStop(emoji.CrossMark)
-				return fmt.Errorf("Pod %s can't connect to Pod %s, check if HostNetwork is configured correctly", clientPod.Name, serverPod.Name)
-			}
-			bandwidth, _ := strconv.ParseFloat(strings.TrimSpace(output), 32)
-			results = append(results, CheckResult{
-				SourceIP:      clientIperfIP,
-				DestinationIP: serverIperfIP,
-				BandwidthMB:   float32(bandwidth),
-			})
+	failed := 0
+	for _, cr := range checkResults {
+		if cr.Err != nil {
+			failed++
 		}
+		result.AddSubResult(cr.SubResult())
 	}
-	hc.SpinnerStop(emoji.CheckMarkButton)
-	for _, result := range results {
-		result.Println()
+	for _, endpoints := range groups {
+		for _, ep := range endpoints {
+			result.AddSubResult(nodeSummary(ep.Node(), ep.Family, checkResults))
+		}
 	}
-	return nil
+	if failed > 0 {
+		result.Failf("%d of %d node pairs failed to measure bandwidth", failed, len(checkResults))
+	}
+	return result
+}
+
+// endpoint is a hostnetwork checker pod and its address in one family of the
+// data network, which an iperf3 server is bound to.
+type endpoint struct {
+	Pod    corev1.Pod
+	IP     string
+	Family network.Family
+}
+
+func (ep endpoint) Node() string {
+	return ep.Pod.Spec.NodeName
 }
 
-func (hc HostNetworkChecker) HostNetworkCheckerDaemonSet(namespace, name string) (*appsv1.DaemonSet, error) {
-	dataCIRD := os.Getenv("IOMESH_DATA_CIDR")
-	_, _, err := net.ParseCIDR(dataCIRD)
+// getEndpoints deploys the hostnetwork checker daemonset and returns one
+// endpoint per node in the primary family of the data network. Nodes whose
+// data network address can't be read are reported as failed sub-results of
+// result and left out.
+func getEndpoints(ctx context.Context, c checker.Checker, result *checker.Result) ([]endpoint, error) {
+	groups, err := getFamilyEndpoints(ctx, c, result)
 	if err != nil {
-		return nil, errors.New("IOMESH_DATA_CIDR is not the correct cidr format. example: IOMESH_DATA_CIDR=192.168.1.0/24")
+		return nil, err
 	}
-	ds := kutils.NewDaemonSet(namespace, name)
-	labels := map[string]string{
-		"app": constant.HostNetworkCheckerLabel,
+	return groups[0], nil
+}
+
+// getFamilyEndpoints is like getEndpoints but returns the endpoints of every
+// family of the data network, primary family first. An iperf3 server is
+// started on each address the image didn't bind its own server to.
+func getFamilyEndpoints(ctx context.Context, c checker.Checker, result *checker.Result) ([][]endpoint, error) {
+	networks, err := config.Get().DataCIDRs()
+	if err != nil {
+		return nil, err
 	}
-	ds.Spec.Selector = &metav1.LabelSelector{
-		MatchLabels: labels,
+	if err := fixture.GetInstance().EnsureHostNetworkDsDeployed(ctx); err != nil {
+		return nil, err
 	}
-	ds.Spec.Template.ObjectMeta.Labels = labels
-	ds.Spec.Template.Spec.HostNetwork = true
-
-	container := corev1.Container{
-		Name:  constant.HostNetworkCheckerLabel,
-		Image: constant.DebugToolsImage,
-		Env: []corev1.EnvVar{
-			{
-				Name:  "DATA_CIDR",
-				Value: dataCIRD,
-			},
-		},
+	pods, err := kutils.ListPods(ctx, c.Client, constant.DebugNamespace, constant.HostNetworkCheckerLabel)
+	if err != nil {
+		return nil, fmt.Errorf("List hostnetwork checker pods: %v", err)
+	}
+	if len(pods) < 2 {
+		return nil, errors.New("Num of nodes less than 2")
 	}
-	ds.Spec.Template.Spec.Containers = append(ds.Spec.Template.Spec.Containers, container)
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].Spec.NodeName < pods[j].Spec.NodeName
+	})
 
-	return ds, nil
+	cidrs := []string{}
+	for _, dataNetwork := range networks {
+		cidrs = append(cidrs, dataNetwork.String())
+	}
+	// starting a second server on an address fails harmlessly, the port
+	// is already taken by the server of the image
+	getAddrsCmd := fmt.Sprintf("for c in %s; do a=$(ip -o addr show to $c | awk '{print $4}' | head -n1); a=${a%%/*}; "+
+		"echo $c $a; [ -n \"$a\" ] && iperf3 -s -D -B $a >/dev/null 2>&1; done; true", strings.Join(cidrs, " "))
+
+	groups := make([][]endpoint, len(networks))
+	for _, pod := range pods {
+		output, err := c.RunCmdInPod(pod.Name, constant.DebugNamespace, getAddrsCmd)
+		if err != nil {
+			result.AddSubResult(checker.SubResult{
+				Node:    pod.Spec.NodeName,
+				Status:  checker.StatusFail,
+				Message: fmt.Sprintf("Get pod %s data network address: %v", pod.Name, err),
+			})
+			continue
+		}
+		addrs := map[string]string{}
+		for _, line := range strings.Split(output, "\n") {
+			if fields := strings.Fields(line); len(fields) == 2 {
+				addrs[fields[0]] = fields[1]
+			}
+		}
+		for i, cidr := range cidrs {
+			if addrs[cidr] == "" {
+				result.AddSubResult(checker.SubResult{
+					Node:    pod.Spec.NodeName,
+					Family:  string(network.FamilyOf(networks[i].IP.String())),
+					Status:  checker.StatusFail,
+					Message: fmt.Sprintf("No address in data network %s", cidr),
+				})
+				continue
+			}
+			groups[i] = append(groups[i], endpoint{
+				Pod:    pod,
+				IP:     addrs[cidr],
+				Family: network.FamilyOf(addrs[cidr]),
+			})
+		}
+	}
+	for i, endpoints := range groups {
+		if len(endpoints) < 2 {
+			return nil, fmt.Errorf("Less than 2 nodes have an address in data network %s", cidrs[i])
+		}
+	}
+	return groups, nil
 }
 
-func (hc HostNetworkChecker) SpinnerStart() {
-	hc.Spinner.Suffix = " Measuring hostnetwork bandwidth"
-	hc.Spinner.Start()
-}
+// measure runs iperf3 from the client against the iperf3 server of server.
+func (hc HostNetworkChecker) measure(client, server endpoint) CheckResult {
+	cr := CheckResult{
+		SourceNode:      client.Node(),
+		DestinationNode: server.Node(),
+		SourceIP:        client.IP,
+		DestinationIP:   server.IP,
+		Family:          client.Family,
+	}
 
-func (hc HostNetworkChecker) SpinnerStop(emoji emoji.Emoji) {
-	hc.Spinner.FinalMSG = fmt.Sprintf("%v Measuring hostnetwork bandwidth\n", emoji)
-	hc.Spinner.Stop()
+	// iperf3 exits non-zero on failure but still prints its JSON report
+	// with the reason, so the exit code is ignored and the report decides
+	doIperfCmd := fmt.Sprintf("iperf3 -J -c %s -t 5; true", server.IP)
+	hc.Log.V(5).Info(doIperfCmd)
+	output, err := hc.RunCmdInPod(client.Pod.Name, constant.DebugNamespace, doIperfCmd)
+	if err != nil {
+		cr.Err = fmt.Errorf("Run iperf3 in pod %s: %v", client.Pod.Name, err)
+		return cr
+	}
+	report, err := network.ParseIperfReport(output)
+	if err != nil {
+		cr.Err = fmt.Errorf("Pod %s can't measure bandwidth to Pod %s: %v", client.Pod.Name, server.Pod.Name, err)
+		return cr
+	}
+	cr.setIperfReport(report)
+	return cr
 }
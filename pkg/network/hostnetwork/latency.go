@@ -0,0 +1,102 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package hostnetwork
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+	"github.com/iomesh/debugtool/pkg/config"
+	"github.com/iomesh/debugtool/pkg/constant"
+	"github.com/iomesh/debugtool/pkg/network"
+)
+
+type LatencyChecker struct {
+	checker.Checker
+}
+
+func NewLatencyChecker() *LatencyChecker {
+	return &LatencyChecker{
+		Checker: checker.Newchecker("HostNetworkLatencyChecker"),
+	}
+}
+
+func init() {
+	checker.Register(NewLatencyChecker())
+}
+
+func (lc LatencyChecker) Name() string {
+	return constant.HostNetworkLatencyCheckName
+}
+
+func (lc LatencyChecker) Category() checker.Category {
+	return checker.CategoryNetwork
+}
+
+func (lc LatencyChecker) Dependencies() []string {
+	return nil
+}
+
+func (lc LatencyChecker) Description() string {
+	return "Measuring hostnetwork latency"
+}
+
+func (lc LatencyChecker) Run(ctx context.Context) checker.Result {
+	result := checker.Result{
+		Status:      checker.StatusPass,
+		Remediation: "Check the switches and NICs of the storage network, latency between storage nodes slows down every replicated write",
+	}
+
+	groups, err := getFamilyEndpoints(ctx, lc.Checker, &result)
+	if err != nil {
+		result.Failf("%v", err)
+		return result
+	}
+
+	for _, endpoints := range groups {
+		subResults := network.MeasurePairs(len(endpoints), func(pair network.Pair) checker.SubResult {
+			return lc.measure(endpoints[pair.Client], endpoints[pair.Server])
+		})
+		for _, sr := range subResults {
+			result.AddSubResult(sr)
+		}
+	}
+
+	if failed := result.Count(checker.StatusFail); failed > 0 {
+		result.Failf("%d node pairs can't reach each other over the data network", failed)
+	} else if slow := result.Count(checker.StatusWarn); slow > 0 {
+		result.Warnf("%d node pairs exceed %.2fms average round trip time", slow, config.Get().MaxLatencyMS)
+	}
+	return result
+}
+
+// measure pings the data network address of server from the data network
+// address of client.
+func (lc LatencyChecker) measure(client, server endpoint) checker.SubResult {
+	sr := checker.SubResult{
+		Node:   client.Node(),
+		Peer:   server.Node(),
+		Family: string(client.Family),
+		Status: checker.StatusPass,
+	}
+	output, err := lc.RunCmdInPod(client.Pod.Name, constant.DebugNamespace, network.PingCmd(client.IP, server.IP))
+	if err != nil {
+		sr.Status = checker.StatusFail
+		sr.Message = fmt.Sprintf("Run ping in pod %s: %v", client.Pod.Name, err)
+		return sr
+	}
+	return network.LatencySubResult(sr, output, config.Get().MaxLatencyMS)
+}
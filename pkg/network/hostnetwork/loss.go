@@ -0,0 +1,102 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package hostnetwork
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+	"github.com/iomesh/debugtool/pkg/config"
+	"github.com/iomesh/debugtool/pkg/constant"
+	"github.com/iomesh/debugtool/pkg/network"
+)
+
+type LossChecker struct {
+	checker.Checker
+}
+
+func NewLossChecker() *LossChecker {
+	return &LossChecker{
+		Checker: checker.Newchecker("HostNetworkLossChecker"),
+	}
+}
+
+func init() {
+	checker.Register(NewLossChecker())
+}
+
+func (lsc LossChecker) Name() string {
+	return constant.HostNetworkLossCheckName
+}
+
+func (lsc LossChecker) Category() checker.Category {
+	return checker.CategoryNetwork
+}
+
+func (lsc LossChecker) Dependencies() []string {
+	return nil
+}
+
+func (lsc LossChecker) Description() string {
+	return "Measuring hostnetwork packet loss"
+}
+
+func (lsc LossChecker) Run(ctx context.Context) checker.Result {
+	result := checker.Result{
+		Status:      checker.StatusPass,
+		Remediation: "Check the switches, cabling and NIC ring buffers of the storage network, lost packets stall replication under load",
+	}
+
+	groups, err := getFamilyEndpoints(ctx, lsc.Checker, &result)
+	if err != nil {
+		result.Failf("%v", err)
+		return result
+	}
+
+	for _, endpoints := range groups {
+		subResults := network.MeasurePairs(len(endpoints), func(pair network.Pair) checker.SubResult {
+			return lsc.measure(endpoints[pair.Client], endpoints[pair.Server])
+		})
+		for _, sr := range subResults {
+			result.AddSubResult(sr)
+		}
+	}
+
+	if failed := result.Count(checker.StatusFail); failed > 0 {
+		result.Failf("%d node pairs lose too many packets or can't be measured", failed)
+	} else if lossy := result.Count(checker.StatusWarn); lossy > 0 {
+		result.Warnf("%d node pairs lose more than %.2f%% packets", lossy, config.Get().WarnLossPercent)
+	}
+	return result
+}
+
+// measure sends UDP traffic from the data network address of client to the
+// iperf3 server of server.
+func (lsc LossChecker) measure(client, server endpoint) checker.SubResult {
+	sr := checker.SubResult{
+		Node:   client.Node(),
+		Peer:   server.Node(),
+		Family: string(client.Family),
+		Status: checker.StatusPass,
+	}
+	output, err := lsc.RunCmdInPod(client.Pod.Name, constant.DebugNamespace, network.UDPLossCmd(client.IP, server.IP, config.Get().LossRate))
+	if err != nil {
+		sr.Status = checker.StatusFail
+		sr.Message = fmt.Sprintf("Run iperf3 in pod %s: %v", client.Pod.Name, err)
+		return sr
+	}
+	return network.LossSubResult(sr, output)
+}
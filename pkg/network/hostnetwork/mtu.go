@@ -0,0 +1,216 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package hostnetwork
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+	"github.com/iomesh/debugtool/pkg/constant"
+	"github.com/iomesh/debugtool/pkg/network"
+)
+
+type MTUChecker struct {
+	checker.Checker
+}
+
+func NewMTUChecker() *MTUChecker {
+	return &MTUChecker{
+		Checker: checker.Newchecker("HostNetworkMTUChecker"),
+	}
+}
+
+func init() {
+	checker.Register(NewMTUChecker())
+}
+
+func (mc MTUChecker) Name() string {
+	return constant.HostNetworkMTUCheckName
+}
+
+func (mc MTUChecker) Category() checker.Category {
+	return checker.CategoryNetwork
+}
+
+func (mc MTUChecker) Dependencies() []string {
+	return nil
+}
+
+func (mc MTUChecker) Description() string {
+	return "Verifying hostnetwork MTU"
+}
+
+// nodeMTU is the interface carrying the data network address of a node.
+type nodeMTU struct {
+	Interface string
+	MTU       int
+}
+
+func (mc MTUChecker) Run(ctx context.Context) checker.Result {
+	result := checker.Result{
+		Status:      checker.StatusPass,
+		Remediation: "Use the same MTU on the data network interface of every node and on every switch port in between",
+	}
+
+	endpoints, err := getEndpoints(ctx, mc.Checker, &result)
+	if err != nil {
+		result.Failf("%v", err)
+		return result
+	}
+
+	// read the MTU of every node first, the path MTU is checked against
+	// the smaller interface MTU of each pair
+	mtus := map[string]nodeMTU{}
+	counts := map[int]int{}
+	reachable := []endpoint{}
+	for _, ep := range endpoints {
+		mtu, err := mc.readMTU(ep)
+		if err != nil {
+			result.AddSubResult(checker.SubResult{
+				Node:    ep.Node(),
+				Status:  checker.StatusFail,
+				Message: err.Error(),
+			})
+			continue
+		}
+		mtus[ep.Node()] = mtu
+		counts[mtu.MTU]++
+		reachable = append(reachable, ep)
+	}
+
+	common := 0
+	for mtu, count := range counts {
+		if count > counts[common] || (count == counts[common] && mtu > common) {
+			common = mtu
+		}
+	}
+	mismatched := 0
+	for _, ep := range reachable {
+		mtu := mtus[ep.Node()]
+		sr := checker.SubResult{
+			Node:    ep.Node(),
+			Status:  checker.StatusPass,
+			Message: fmt.Sprintf("%s on %s", mtu.Interface, ep.IP),
+			Measurements: []checker.Measurement{
+				{Name: "mtu", Value: float64(mtu.MTU)},
+			},
+		}
+		if mtu.MTU != common {
+			mismatched++
+			sr.Status = checker.StatusFail
+			sr.Message = fmt.Sprintf("%s on %s has MTU %d while the other nodes use %d", mtu.Interface, ep.IP, mtu.MTU, common)
+		}
+		result.AddSubResult(sr)
+	}
+
+	subResults := network.MeasurePairs(len(reachable), func(pair network.Pair) checker.SubResult {
+		client, server := reachable[pair.Client], reachable[pair.Server]
+		return mc.measurePathMTU(client, server, pairMTU(mtus, client.Node(), server.Node()))
+	})
+	brokenPaths := []string{}
+	for _, sr := range subResults {
+		if sr.Status != checker.StatusPass {
+			brokenPaths = append(brokenPaths, fmt.Sprintf("%s (%d)", sr.Name(), pairMTU(mtus, sr.Node, sr.Peer)))
+		}
+		result.AddSubResult(sr)
+	}
+
+	switch {
+	case mismatched > 0:
+		result.Failf("%d nodes differ from the common MTU %d", mismatched, common)
+	case len(brokenPaths) > 0:
+		result.Failf("%d node pairs can't pass packets of their interface MTU without fragmentation: %s", len(brokenPaths), strings.Join(brokenPaths, ", "))
+	case result.Status == checker.StatusFail:
+		result.Failf("Failed to read the MTU of some nodes")
+	default:
+		result.Message = fmt.Sprintf("MTU %d on every node and path", common)
+	}
+	return result
+}
+
+// pairMTU is the MTU the path between two nodes is expected to carry, the
+// smaller interface MTU of the two.
+func pairMTU(mtus map[string]nodeMTU, node, peer string) int {
+	expected := mtus[node].MTU
+	if peerMTU := mtus[peer].MTU; peerMTU < expected {
+		expected = peerMTU
+	}
+	return expected
+}
+
+// readMTU finds the interface holding the data network address of the node
+// and reads its MTU.
+func (mc MTUChecker) readMTU(ep endpoint) (nodeMTU, error) {
+	readMTUCmd := fmt.Sprintf("IF=$(ip -o addr show to %s | awk '{print $2}' | head -n1); echo $IF $(cat /sys/class/net/$IF/mtu)", ep.IP)
+	output, err := mc.RunCmdInPod(ep.Pod.Name, constant.DebugNamespace, readMTUCmd)
+	if err != nil {
+		return nodeMTU{}, fmt.Errorf("Read MTU in pod %s: %v", ep.Pod.Name, err)
+	}
+	fields := strings.Fields(output)
+	if len(fields) != 2 {
+		return nodeMTU{}, fmt.Errorf("No interface holds data network address %s", ep.IP)
+	}
+	mtu, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nodeMTU{}, fmt.Errorf("Parse MTU %q of %s: %v", fields[1], fields[0], err)
+	}
+	return nodeMTU{Interface: fields[0], MTU: mtu}, nil
+}
+
+// measurePathMTU sends don't-fragment pings of the expected MTU from client to
+// server. If they don't get through, the largest size that does is searched.
+func (mc MTUChecker) measurePathMTU(client, server endpoint, expected int) checker.SubResult {
+	sr := checker.SubResult{
+		Node:   client.Node(),
+		Peer:   server.Node(),
+		Status: checker.StatusPass,
+	}
+
+	headerSize := client.Family.ICMPHeaderSize()
+	pathMTU := expected
+	if !mc.pingDF(client, server, expected-headerSize) {
+		// binary search the largest payload getting through
+		low, high := 0, expected-headerSize
+		if !mc.pingDF(client, server, low) {
+			sr.Status = checker.StatusFail
+			sr.Message = fmt.Sprintf("%s can't ping %s", client.IP, server.IP)
+			return sr
+		}
+		for high-low > 1 {
+			mid := (low + high) / 2
+			if mc.pingDF(client, server, mid) {
+				low = mid
+			} else {
+				high = mid
+			}
+		}
+		pathMTU = low + headerSize
+		sr.Status = checker.StatusFail
+		sr.Message = fmt.Sprintf("Path MTU %d is smaller than interface MTU %d", pathMTU, expected)
+	}
+	sr.Measurements = []checker.Measurement{
+		{Name: "path mtu", Value: float64(pathMTU)},
+	}
+	return sr
+}
+
+func (mc MTUChecker) pingDF(client, server endpoint, payload int) bool {
+	pingCmd := fmt.Sprintf("ping -n -M do -c 3 -i 0.2 -W 1 -s %d -I %s %s", payload, client.IP, server.IP)
+	_, err := mc.RunCmdInPod(client.Pod.Name, constant.DebugNamespace, pingCmd)
+	return err == nil
+}
@@ -0,0 +1,353 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package hostnetwork
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+	"github.com/iomesh/debugtool/pkg/config"
+	"github.com/iomesh/debugtool/pkg/constant"
+)
+
+// readNICCmd prints the link state, driver, bonding, error counters and
+// offloads of the interface holding the given address as key=value lines.
+// For a VLAN the link information is read from the underlying device.
+const readNICCmd = `raw=$(ip -o addr show to %s | awk '{print $2}' | head -n1)
+[ -z "$raw" ] && exit 0
+IF=${raw%%%%@*}; LINK=$IF
+case $raw in *@*) LINK=${raw#*@};; esac
+D=/sys/class/net/$LINK
+echo interface=$IF
+echo link=$LINK
+echo driver=$(basename "$(readlink $D/device/driver)")
+echo speed=$(cat $D/speed 2>/dev/null)
+echo duplex=$(cat $D/duplex 2>/dev/null)
+echo operstate=$(cat $D/operstate)
+for s in rx_errors tx_errors rx_dropped tx_dropped; do echo $s=$(cat $D/statistics/$s); done
+if [ -d $D/bonding ]; then
+  echo driver=bonding
+  echo bond_mode=$(cut -d' ' -f1 $D/bonding/mode)
+  for s in $(cat $D/bonding/slaves); do echo slave=$s $(cat /sys/class/net/$s/operstate) $(cat /sys/class/net/$s/speed 2>/dev/null); done
+fi
+ethtool -k $IF 2>/dev/null | grep -E '^(rx-checksumming|tx-checksumming|scatter-gather|tcp-segmentation-offload|generic-segmentation-offload|generic-receive-offload|large-receive-offload):' | sed 's/^/offload=/'
+true`
+
+var (
+	bandwidthLock sync.Mutex
+	// measuredBandwidth is the best bandwidth in MB/s each node reached
+	// with any peer in the last bandwidth check.
+	measuredBandwidth = map[string]float64{}
+)
+
+// recordBandwidth keeps the bandwidth measured by the bandwidth check, so the
+// NIC check can compare it to the link speed.
+func recordBandwidth(results []CheckResult) {
+	bandwidthLock.Lock()
+	defer bandwidthLock.Unlock()
+
+	measuredBandwidth = map[string]float64{}
+	for _, cr := range results {
+		if cr.Err != nil {
+			continue
+		}
+		for _, node := range []string{cr.SourceNode, cr.DestinationNode} {
+			if bandwidth := float64(cr.BandwidthMB); bandwidth > measuredBandwidth[node] {
+				measuredBandwidth[node] = bandwidth
+			}
+		}
+	}
+}
+
+func bestBandwidth(node string) (float64, bool) {
+	bandwidthLock.Lock()
+	defer bandwidthLock.Unlock()
+
+	bandwidth, ok := measuredBandwidth[node]
+	return bandwidth, ok
+}
+
+// NICInfo describes the interface carrying the data network on a node.
+type NICInfo struct {
+	Interface string `json:"interface"`
+	// Link is the device the link information was read from, the
+	// underlying device of a VLAN or else the interface itself.
+	Link      string            `json:"link"`
+	Driver    string            `json:"driver,omitempty"`
+	SpeedMbps int               `json:"speedMbps"`
+	Duplex    string            `json:"duplex,omitempty"`
+	OperState string            `json:"operState"`
+	BondMode  string            `json:"bondMode,omitempty"`
+	Slaves    []BondSlave       `json:"slaves,omitempty"`
+	Offloads  map[string]string `json:"offloads,omitempty"`
+	RXErrors  uint64            `json:"rxErrors"`
+	TXErrors  uint64            `json:"txErrors"`
+	RXDropped uint64            `json:"rxDropped"`
+	TXDropped uint64            `json:"txDropped"`
+}
+
+type BondSlave struct {
+	Interface string `json:"interface"`
+	OperState string `json:"operState"`
+	SpeedMbps int    `json:"speedMbps"`
+}
+
+// effectiveSpeedMbps is the speed a single TCP stream can reach. Bonds hash
+// every stream to one slave, so it is the speed of the fastest slave.
+func (nic NICInfo) effectiveSpeedMbps() int {
+	speed := 0
+	for _, slave := range nic.Slaves {
+		if slave.OperState == "up" && slave.SpeedMbps > speed {
+			speed = slave.SpeedMbps
+		}
+	}
+	if speed > 0 {
+		return speed
+	}
+	return nic.SpeedMbps
+}
+
+type NICChecker struct {
+	checker.Checker
+}
+
+func NewNICChecker() *NICChecker {
+	return &NICChecker{
+		Checker: checker.Newchecker("HostNetworkNICChecker"),
+	}
+}
+
+func init() {
+	checker.Register(NewNICChecker())
+}
+
+func (nc NICChecker) Name() string {
+	return constant.HostNetworkNICCheckName
+}
+
+func (nc NICChecker) Category() checker.Category {
+	return checker.CategoryNetwork
+}
+
+// Dependencies is empty on purpose: the NIC inventory helps most when the
+// bandwidth check fails. The bandwidth measured by a preceding bandwidth
+// check of the same run is used when there is one.
+func (nc NICChecker) Dependencies() []string {
+	return nil
+}
+
+// After orders the check after the bandwidth check when both are selected.
+func (nc NICChecker) After() []string {
+	return []string{constant.HostNetworkBandwidthCheckName}
+}
+
+func (nc NICChecker) Description() string {
+	return "Inspecting hostnetwork NICs"
+}
+
+func (nc NICChecker) Run(ctx context.Context) checker.Result {
+	result := checker.Result{
+		Status:      checker.StatusPass,
+		Remediation: "Check cabling, switch ports, bonding and driver of the data network interfaces, the negotiated link speed should match the hardware",
+	}
+
+	endpoints, err := getEndpoints(ctx, nc.Checker, &result)
+	if err != nil {
+		result.Failf("%v", err)
+		return result
+	}
+
+	down, slow := 0, 0
+	for _, ep := range endpoints {
+		sr := nc.inspect(ep)
+		switch {
+		case sr.Status == checker.StatusFail:
+			down++
+		case sr.Status == checker.StatusWarn:
+			slow++
+		}
+		result.AddSubResult(sr)
+	}
+
+	switch {
+	case down > 0:
+		result.Failf("The data network interface of %d nodes is down or unreadable", down)
+	case slow > 0:
+		result.Warnf("The data network interface of %d nodes has link issues", slow)
+	default:
+		result.Message = "Data network interface of every node is up"
+	}
+	return result
+}
+
+func (nc NICChecker) inspect(ep endpoint) checker.SubResult {
+	sr := checker.SubResult{
+		Node:   ep.Node(),
+		Status: checker.StatusPass,
+	}
+	output, err := nc.RunCmdInPod(ep.Pod.Name, constant.DebugNamespace, fmt.Sprintf(readNICCmd, ep.IP))
+	if err != nil {
+		sr.Status = checker.StatusFail
+		sr.Message = fmt.Sprintf("Read NIC in pod %s: %v", ep.Pod.Name, err)
+		return sr
+	}
+	nic := ParseNICInfo(output)
+	if nic.Interface == "" {
+		sr.Status = checker.StatusFail
+		sr.Message = fmt.Sprintf("No interface holds data network address %s", ep.IP)
+		return sr
+	}
+	sr.Details = nic
+	sr.Measurements = []checker.Measurement{
+		{Name: "link speed", Value: float64(nic.SpeedMbps), Unit: "Mb/s"},
+		{Name: "rx errors", Value: float64(nic.RXErrors)},
+		{Name: "tx errors", Value: float64(nic.TXErrors)},
+	}
+
+	issues := []string{}
+	if nic.OperState != "up" && nic.OperState != "unknown" {
+		sr.Status = checker.StatusFail
+		issues = append(issues, fmt.Sprintf("link is %s", nic.OperState))
+	}
+	if nic.Duplex != "" && nic.Duplex != "full" && nic.Duplex != "unknown" {
+		issues = append(issues, fmt.Sprintf("%s duplex", nic.Duplex))
+	}
+	for _, slave := range nic.Slaves {
+		if slave.OperState != "up" {
+			issues = append(issues, fmt.Sprintf("bond slave %s is %s", slave.Interface, slave.OperState))
+		}
+	}
+	if nic.RXErrors+nic.TXErrors > 0 {
+		issues = append(issues, fmt.Sprintf("%d rx and %d tx errors", nic.RXErrors, nic.TXErrors))
+	}
+	bandwidth, measured := bestBandwidth(ep.Node())
+	if measured && nic.effectiveSpeedMbps() > 0 {
+		// link speed is in Mb/s and bandwidth in MB/s
+		utilization := bandwidth * 8 / float64(nic.effectiveSpeedMbps()) * 100
+		sr.Measurements = append(sr.Measurements,
+			checker.Measurement{Name: "bandwidth", Value: bandwidth, Unit: "MB/s"},
+			checker.Measurement{Name: "utilization", Value: utilization, Unit: "%"},
+		)
+		if utilization < config.Get().MinLinkUtilizationPercent {
+			issues = append(issues, fmt.Sprintf("best bandwidth %.0fMB/s is %.0f%% of the %dMb/s link", bandwidth, utilization, nic.effectiveSpeedMbps()))
+		}
+	}
+	if len(issues) > 0 && sr.Status == checker.StatusPass {
+		sr.Status = checker.StatusWarn
+	}
+
+	sr.Message = nic.describe()
+	if len(issues) > 0 {
+		sr.Message += ": " + strings.Join(issues, ", ")
+	}
+	if !measured {
+		// only a note, the link utilization is unknown without a
+		// bandwidth check in the same run
+		sr.Message += " (bandwidth not measured in this run)"
+	}
+	return sr
+}
+
+func (nic NICInfo) describe() string {
+	desc := nic.Interface
+	if nic.Link != nic.Interface {
+		desc += " on " + nic.Link
+	}
+	if nic.Driver != "" {
+		desc += " " + nic.Driver
+	}
+	if nic.BondMode != "" {
+		desc += " " + nic.BondMode
+	}
+	if nic.SpeedMbps > 0 {
+		desc += fmt.Sprintf(" %dMb/s", nic.SpeedMbps)
+	}
+	if nic.Duplex != "" {
+		desc += " " + nic.Duplex
+	}
+	return desc
+}
+
+// ParseNICInfo parses the key=value lines printed by readNICCmd.
+func ParseNICInfo(output string) NICInfo {
+	nic := NICInfo{Offloads: map[string]string{}}
+	for _, line := range strings.Split(output, "\n") {
+		kv := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key, value := kv[0], strings.TrimSpace(kv[1])
+		switch key {
+		case "interface":
+			nic.Interface = value
+		case "link":
+			nic.Link = value
+		case "driver":
+			nic.Driver = value
+		case "speed":
+			nic.SpeedMbps = parseSpeed(value)
+		case "duplex":
+			nic.Duplex = value
+		case "operstate":
+			nic.OperState = value
+		case "bond_mode":
+			nic.BondMode = value
+		case "rx_errors":
+			nic.RXErrors, _ = strconv.ParseUint(value, 10, 64)
+		case "tx_errors":
+			nic.TXErrors, _ = strconv.ParseUint(value, 10, 64)
+		case "rx_dropped":
+			nic.RXDropped, _ = strconv.ParseUint(value, 10, 64)
+		case "tx_dropped":
+			nic.TXDropped, _ = strconv.ParseUint(value, 10, 64)
+		case "slave":
+			// eth2 up 10000
+			fields := strings.Fields(value)
+			if len(fields) < 2 {
+				continue
+			}
+			slave := BondSlave{Interface: fields[0], OperState: fields[1]}
+			if len(fields) > 2 {
+				slave.SpeedMbps = parseSpeed(fields[2])
+			}
+			nic.Slaves = append(nic.Slaves, slave)
+		case "offload":
+			// tcp-segmentation-offload: on
+			parts := strings.SplitN(value, ":", 2)
+			if len(parts) == 2 && len(strings.Fields(parts[1])) > 0 {
+				nic.Offloads[parts[0]] = strings.Fields(parts[1])[0]
+			}
+		}
+	}
+	sort.Slice(nic.Slaves, func(i, j int) bool {
+		return nic.Slaves[i].Interface < nic.Slaves[j].Interface
+	})
+	return nic
+}
+
+// parseSpeed parses a link speed in Mb/s, unknown speeds are reported as -1
+// by the kernel and returned as 0.
+func parseSpeed(value string) int {
+	speed, err := strconv.Atoi(value)
+	if err != nil || speed < 0 {
+		return 0
+	}
+	return speed
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package hostnetwork
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNICInfo(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   NICInfo
+		speed  int
+	}{
+		{
+			name:   "empty output",
+			output: "",
+			want:   NICInfo{Offloads: map[string]string{}},
+		},
+		{
+			name: "physical interface",
+			output: `interface=eth1
+link=eth1
+driver=ixgbe
+speed=10000
+duplex=full
+operstate=up
+rx_errors=3
+tx_errors=0
+rx_dropped=12
+tx_dropped=1
+offload=tcp-segmentation-offload: on
+offload=generic-receive-offload: off [fixed]
+`,
+			want: NICInfo{
+				Interface: "eth1", Link: "eth1", Driver: "ixgbe", SpeedMbps: 10000, Duplex: "full", OperState: "up",
+				RXErrors: 3, RXDropped: 12, TXDropped: 1,
+				Offloads: map[string]string{"tcp-segmentation-offload": "on", "generic-receive-offload": "off"},
+			},
+			speed: 10000,
+		},
+		{
+			name: "bond with a slave down",
+			output: `interface=bond0.100
+link=bond0
+driver=bonding
+speed=20000
+operstate=up
+bond_mode=802.3ad 4
+slave=eth3 down -1
+slave=eth2 up 10000
+`,
+			want: NICInfo{
+				Interface: "bond0.100", Link: "bond0", Driver: "bonding", SpeedMbps: 20000, OperState: "up", BondMode: "802.3ad 4",
+				Slaves: []BondSlave{
+					{Interface: "eth2", OperState: "up", SpeedMbps: 10000},
+					{Interface: "eth3", OperState: "down"},
+				},
+				Offloads: map[string]string{},
+			},
+			speed: 10000,
+		},
+		{
+			name: "malformed lines",
+			output: `interface=eth0
+no separator here
+speed=-1
+speed_unknown
+rx_errors=lots
+slave=eth2
+offload=tcp-segmentation-offload:
+offload=no colon
+=value
+`,
+			want: NICInfo{Interface: "eth0", Offloads: map[string]string{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseNICInfo(tt.output)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseNICInfo() = %+v, want %+v", got, tt.want)
+			}
+			if speed := got.effectiveSpeedMbps(); speed != tt.speed {
+				t.Errorf("effectiveSpeedMbps() = %d, want %d", speed, tt.speed)
+			}
+		})
+	}
+}
@@ -0,0 +1,351 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package hostnetwork
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+	"github.com/iomesh/debugtool/pkg/config"
+	"github.com/iomesh/debugtool/pkg/constant"
+	"github.com/iomesh/debugtool/pkg/network"
+)
+
+// Port states seen by a connecting client. A refused connection means the
+// host or a REJECT rule answered, a filtered one that the packets were
+// dropped on the way.
+const (
+	PortOpen        = "open"
+	PortRefused     = "refused"
+	PortFiltered    = "filtered"
+	PortUnreachable = "unreachable"
+)
+
+// listenersFile holds the PIDs of the temporary listeners of a node.
+const listenersFile = "/tmp/iomesh-port-listeners"
+
+// listenerLifetime bounds how long a temporary listener outlives the check
+// if it can't be stopped.
+const listenerLifetime = "10m"
+
+// PortsChecker verifies the IOMesh ports are free on every node and reachable
+// from every other node through the data network.
+type PortsChecker struct {
+	checker.Checker
+}
+
+func NewPortsChecker() *PortsChecker {
+	return &PortsChecker{
+		Checker: checker.Newchecker("HostNetworkPortsChecker"),
+	}
+}
+
+func init() {
+	checker.Register(NewPortsChecker())
+}
+
+func (pc PortsChecker) Name() string {
+	return constant.HostNetworkPortsCheckName
+}
+
+func (pc PortsChecker) Category() checker.Category {
+	return checker.CategoryNetwork
+}
+
+func (pc PortsChecker) Dependencies() []string {
+	return nil
+}
+
+func (pc PortsChecker) Description() string {
+	return "Checking IOMesh ports"
+}
+
+func (pc PortsChecker) Run(ctx context.Context) checker.Result {
+	result := checker.Result{
+		Status:      checker.StatusPass,
+		Remediation: "Stop the services using the IOMesh ports and allow the IOMesh ports between the nodes in the host firewall and the network ACLs",
+	}
+	ports := config.Get().Ports
+	if len(ports) == 0 {
+		result.Message = "No port to check"
+		return result
+	}
+
+	endpoints, err := getEndpoints(ctx, pc.Checker, &result)
+	if err != nil {
+		result.Failf("%v", err)
+		return result
+	}
+
+	// listen on every free port, so an open port tells a reachable one
+	// apart from a refused one
+	busyNodes, notStartedNodes := 0, 0
+	nodes := make([]nodePorts, len(endpoints))
+	for i, ep := range endpoints {
+		sr, np := pc.checkFree(ep, ports)
+		if len(np.Busy) > 0 {
+			busyNodes++
+		}
+		if len(np.NotStarted) > 0 {
+			notStartedNodes++
+		}
+		nodes[i] = np
+		result.AddSubResult(sr)
+	}
+	defer func() {
+		for _, ep := range endpoints {
+			pc.stopListeners(ep)
+		}
+	}()
+
+	// only the ports listened on are probed, a port without listener would
+	// be refused and blamed on the network
+	subResults := network.MeasurePairs(len(endpoints), func(pair network.Pair) checker.SubResult {
+		return pc.probe(endpoints[pair.Client], endpoints[pair.Server], nodes[pair.Server].Listening)
+	})
+	blockedPairs := 0
+	for _, sr := range subResults {
+		if sr.Status == checker.StatusFail {
+			blockedPairs++
+		}
+		result.AddSubResult(sr)
+	}
+
+	problems := []string{}
+	if busyNodes > 0 {
+		problems = append(problems, fmt.Sprintf("IOMesh ports are in use on %d nodes", busyNodes))
+	}
+	if notStartedNodes > 0 {
+		problems = append(problems, fmt.Sprintf("port listeners could not be started on %d nodes", notStartedNodes))
+	}
+	if blockedPairs > 0 {
+		problems = append(problems, fmt.Sprintf("IOMesh ports are blocked between %d node pairs", blockedPairs))
+	}
+	switch {
+	case len(problems) > 0:
+		result.Failf("%s", strings.Join(problems, ", "))
+	case result.Status == checker.StatusFail:
+		result.Failf("Failed to check the ports of some nodes")
+	default:
+		result.Message = fmt.Sprintf("%d ports free and reachable on every node", len(ports))
+	}
+	return result
+}
+
+// nodePorts are the IOMesh ports of a node by state.
+type nodePorts struct {
+	// Busy ports are listened on by another process.
+	Busy []int
+	// NotStarted ports are free but no temporary listener could be
+	// started on them.
+	NotStarted []int
+	// Listening ports are listened on, by another process or a temporary
+	// listener.
+	Listening []int
+}
+
+// checkFree reports the ports already listened on in the host network of the
+// node and starts a temporary listener on the data network address for each
+// free port. The listeners are started in the background, so the listening
+// ports are listed again to find those that didn't start.
+func (pc PortsChecker) checkFree(ep endpoint, ports []int) (checker.SubResult, nodePorts) {
+	sr := checker.SubResult{
+		Node:   ep.Node(),
+		Status: checker.StatusPass,
+	}
+	np := nodePorts{}
+	listening, err := pc.listeningPorts(ep)
+	if err != nil {
+		sr.Status = checker.StatusFail
+		sr.Message = err.Error()
+		return sr, np
+	}
+
+	free := []int{}
+	for _, port := range ports {
+		if listening[port] {
+			np.Busy = append(np.Busy, port)
+			np.Listening = append(np.Listening, port)
+		} else {
+			free = append(free, port)
+		}
+	}
+	if len(free) > 0 {
+		startCmd := fmt.Sprintf("for p in %s; do nohup timeout %s nc -lk %s $p >/dev/null 2>&1 & echo $! >> %s; done; sleep 1",
+			strings.Trim(fmt.Sprint(free), "[]"), listenerLifetime, ep.IP, listenersFile)
+		if _, err := pc.RunCmdInPod(ep.Pod.Name, constant.DebugNamespace, startCmd); err != nil {
+			pc.Log.Error(err, "Start port listeners fail", "pod", ep.Pod.Name)
+		}
+		started, err := pc.listeningPorts(ep)
+		if err != nil {
+			sr.Status = checker.StatusFail
+			sr.Message = err.Error()
+			return sr, np
+		}
+		for _, port := range free {
+			if started[port] {
+				np.Listening = append(np.Listening, port)
+			} else {
+				np.NotStarted = append(np.NotStarted, port)
+			}
+		}
+	}
+
+	problems := []string{}
+	if len(np.Busy) > 0 {
+		problems = append(problems, fmt.Sprintf("Ports %s are already in use", joinPorts(np.Busy)))
+	}
+	if len(np.NotStarted) > 0 {
+		problems = append(problems, fmt.Sprintf("Listener could not be started on ports %s, they are not probed", joinPorts(np.NotStarted)))
+	}
+	if len(problems) > 0 {
+		sr.Status = checker.StatusFail
+		sr.Message = strings.Join(problems, "; ")
+		return sr, np
+	}
+	sr.Message = fmt.Sprintf("%d ports free", len(ports))
+	return sr, np
+}
+
+// listeningPorts lists the ports listened on in the host network of the node.
+func (pc PortsChecker) listeningPorts(ep endpoint) (map[int]bool, error) {
+	output, err := pc.RunCmdInPod(ep.Pod.Name, constant.DebugNamespace, "ss -ltn | awk 'NR>1 {print $4}'")
+	if err != nil {
+		return nil, fmt.Errorf("List listening ports in pod %s: %v", ep.Pod.Name, err)
+	}
+	return ParseListeningPorts(output), nil
+}
+
+func joinPorts(ports []int) string {
+	strs := []string{}
+	for _, port := range ports {
+		strs = append(strs, strconv.Itoa(port))
+	}
+	return strings.Join(strs, ", ")
+}
+
+func (pc PortsChecker) stopListeners(ep endpoint) {
+	stopCmd := fmt.Sprintf("kill $(cat %[1]s) 2>/dev/null; rm -f %[1]s; true", listenersFile)
+	if _, err := pc.RunCmdInPod(ep.Pod.Name, constant.DebugNamespace, stopCmd); err != nil {
+		pc.Log.Error(err, "Stop port listeners fail", "pod", ep.Pod.Name)
+	}
+}
+
+// probe connects from client to every port of server in parallel and reports
+// the ports that aren't open.
+func (pc PortsChecker) probe(client, server endpoint, ports []int) checker.SubResult {
+	sr := checker.SubResult{
+		Node:   client.Node(),
+		Peer:   server.Node(),
+		Status: checker.StatusPass,
+	}
+	if len(ports) == 0 {
+		sr.Status = checker.StatusSkip
+		sr.Message = fmt.Sprintf("No port listened on %s to probe", server.IP)
+		return sr
+	}
+
+	probeCmd := ""
+	for _, port := range ports {
+		// bash reports a refused connection on stderr, timeout exits 124
+		// when the SYN got no answer at all
+		probeCmd += fmt.Sprintf(`(r=$(timeout 3 bash -c 'exec 3<>/dev/tcp/%[1]s/%[2]d' 2>&1); c=$?; `+
+			`if [ $c -eq 0 ]; then s=%[3]s; elif [ $c -eq 124 ]; then s=%[4]s; `+
+			`else case "$r" in *refused*) s=%[5]s;; *) s=%[6]s;; esac; fi; echo %[2]d $s) & `,
+			server.IP, port, PortOpen, PortFiltered, PortRefused, PortUnreachable)
+	}
+	probeCmd += "wait"
+	output, err := pc.RunCmdInPod(client.Pod.Name, constant.DebugNamespace, probeCmd)
+	if err != nil {
+		sr.Status = checker.StatusFail
+		sr.Message = fmt.Sprintf("Probe ports in pod %s: %v", client.Pod.Name, err)
+		return sr
+	}
+
+	states := ParsePortStates(output)
+	sr.Details = states
+	open, problems := SummarizePortStates(states, ports)
+	sr.Measurements = []checker.Measurement{
+		{Name: "open ports", Value: float64(open)},
+	}
+	if len(problems) == 0 {
+		sr.Message = fmt.Sprintf("%d ports open on %s", open, server.IP)
+		return sr
+	}
+
+	sr.Status = checker.StatusFail
+	sr.Message = fmt.Sprintf("%s: %s", server.IP, strings.Join(problems, "; "))
+	return sr
+}
+
+// SummarizePortStates counts the open ports and lists the others by state. A
+// port without a probe line is unreachable, its probe didn't finish.
+func SummarizePortStates(states map[int]string, ports []int) (int, []string) {
+	byState := map[string][]string{}
+	open := 0
+	for _, port := range ports {
+		state, ok := states[port]
+		if !ok {
+			state = PortUnreachable
+		}
+		if state == PortOpen {
+			open++
+			continue
+		}
+		byState[state] = append(byState[state], strconv.Itoa(port))
+	}
+	problems := []string{}
+	for _, state := range []string{PortFiltered, PortRefused, PortUnreachable} {
+		if len(byState[state]) > 0 {
+			problems = append(problems, fmt.Sprintf("%s %s", state, strings.Join(byState[state], ", ")))
+		}
+	}
+	return open, problems
+}
+
+// ParseListeningPorts parses the local addresses printed by ss, such as
+// 0.0.0.0:22 or [::]:2379, and returns the set of ports.
+func ParseListeningPorts(output string) map[int]bool {
+	ports := map[int]bool{}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		i := strings.LastIndex(line, ":")
+		if i < 0 {
+			continue
+		}
+		if port, err := strconv.Atoi(line[i+1:]); err == nil {
+			ports[port] = true
+		}
+	}
+	return ports
+}
+
+// ParsePortStates parses the "port state" lines printed by the probes.
+func ParsePortStates(output string) map[int]string {
+	states := map[int]string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if port, err := strconv.Atoi(fields[0]); err == nil {
+			states[port] = fields[1]
+		}
+	}
+	return states
+}
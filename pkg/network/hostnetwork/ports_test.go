@@ -0,0 +1,116 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package hostnetwork
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseListeningPorts(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   map[int]bool
+	}{
+		{name: "empty", output: "", want: map[int]bool{}},
+		{name: "garbage", output: "ss: command not found\nState\n", want: map[int]bool{}},
+		{
+			name: "ipv4",
+			output: `0.0.0.0:22
+127.0.0.1:10248
+192.168.10.11:2379
+127.0.0.53%lo:53
+`,
+			want: map[int]bool{22: true, 10248: true, 2379: true, 53: true},
+		},
+		{
+			name: "ipv6 and wildcards",
+			output: `[::]:22
+*:10250
+[::1]:10249
+[fd00:10::11]:10201
+[::ffff:192.168.10.11]:10206
+[fe80::1%eth0]:546
+`,
+			want: map[int]bool{22: true, 10250: true, 10249: true, 10201: true, 10206: true, 546: true},
+		},
+		{
+			name:   "malformed port",
+			output: "0.0.0.0:*\n[::]:\n0.0.0.0:ssh\n",
+			want:   map[int]bool{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseListeningPorts(tt.output); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseListeningPorts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePortStates(t *testing.T) {
+	ports := []int{10201, 10206, 10100, 2379}
+	tests := []struct {
+		name     string
+		output   string
+		states   map[int]string
+		open     int
+		problems []string
+	}{
+		{
+			name:     "empty output",
+			output:   "",
+			states:   map[int]string{},
+			problems: []string{"unreachable 10201, 10206, 10100, 2379"},
+		},
+		{
+			name:   "all open",
+			output: "10206 open\n10201 open\n2379 open\n10100 open\n",
+			states: map[int]string{10201: PortOpen, 10206: PortOpen, 10100: PortOpen, 2379: PortOpen},
+			open:   4,
+		},
+		{
+			name:   "missing probe lines",
+			output: "10201 open\n10100 refused\n",
+			states: map[int]string{10201: PortOpen, 10100: PortRefused},
+			open:   1,
+			// ports without a line default to unreachable
+			problems: []string{"refused 10100", "unreachable 10206, 2379"},
+		},
+		{
+			name:     "mixed states and garbage",
+			output:   "10201 filtered\nbash: warning\n10206 filtered\nopen 10100\n2379 open extra\n10100 unreachable\n",
+			states:   map[int]string{10201: PortFiltered, 10206: PortFiltered, 10100: PortUnreachable},
+			problems: []string{"filtered 10201, 10206", "unreachable 10100, 2379"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			states := ParsePortStates(tt.output)
+			if !reflect.DeepEqual(states, tt.states) {
+				t.Errorf("ParsePortStates() = %v, want %v", states, tt.states)
+			}
+			open, problems := SummarizePortStates(states, ports)
+			if tt.problems == nil {
+				tt.problems = []string{}
+			}
+			if open != tt.open || !reflect.DeepEqual(problems, tt.problems) {
+				t.Errorf("SummarizePortStates() = %d, %q, want %d, %q", open, problems, tt.open, tt.problems)
+			}
+		})
+	}
+}
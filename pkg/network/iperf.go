@@ -0,0 +1,98 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// IperfReport is the part of the `iperf3 -J` output used by the checkers.
+type IperfReport struct {
+	Intervals []IperfInterval `json:"intervals"`
+	End       IperfEnd        `json:"end"`
+	Error     string          `json:"error"`
+}
+
+// IperfUDPStream is the per stream summary of a UDP test.
+type IperfUDPStream struct {
+	UDP struct {
+		OutOfOrder int `json:"out_of_order"`
+	} `json:"udp"`
+}
+
+type IperfInterval struct {
+	Sum IperfSum `json:"sum"`
+}
+
+type IperfSum struct {
+	Start         float64 `json:"start"`
+	End           float64 `json:"end"`
+	Seconds       float64 `json:"seconds"`
+	Bytes         int64   `json:"bytes"`
+	BitsPerSecond float64 `json:"bits_per_second"`
+	Retransmits   int     `json:"retransmits"`
+
+	// only reported by UDP tests
+	JitterMS    float64 `json:"jitter_ms"`
+	LostPackets int     `json:"lost_packets"`
+	Packets     int     `json:"packets"`
+	LostPercent float64 `json:"lost_percent"`
+	OutOfOrder  int     `json:"out_of_order"`
+}
+
+type IperfEnd struct {
+	Streams               []IperfUDPStream `json:"streams"`
+	SumSent               IperfSum         `json:"sum_sent"`
+	SumReceived           IperfSum         `json:"sum_received"`
+	Sum                   IperfSum         `json:"sum"`
+	CPUUtilizationPercent IperfCPU         `json:"cpu_utilization_percent"`
+}
+
+// IperfCPU is the CPU utilization of the client (host) and the server
+// (remote) during the test.
+type IperfCPU struct {
+	HostTotal   float64 `json:"host_total"`
+	RemoteTotal float64 `json:"remote_total"`
+}
+
+// ParseIperfReport decodes the output of `iperf3 -J`. iperf3 reports most
+// failures, like a refused connection, in the error field of the JSON
+// document rather than through its exit code alone.
+func ParseIperfReport(output string) (*IperfReport, error) {
+	output = strings.TrimSpace(output)
+	if output == "" {
+		return nil, errors.New("empty iperf3 output")
+	}
+	report := &IperfReport{}
+	if err := json.Unmarshal([]byte(output), report); err != nil {
+		return nil, fmt.Errorf("decode iperf3 json output: %v", err)
+	}
+	if report.Error != "" {
+		return nil, fmt.Errorf("iperf3: %s", report.Error)
+	}
+	// TCP tests are summarized in sum_sent/sum_received, UDP tests in sum
+	if report.End.SumReceived.Seconds == 0 && report.End.Sum.Seconds == 0 {
+		return nil, errors.New("iperf3 output has no summary")
+	}
+	return report, nil
+}
+
+// BitsToMB converts bits per second to megabytes per second.
+func BitsToMB(bitsPerSecond float64) float64 {
+	return bitsPerSecond / 8 / 1000 / 1000
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package network
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseIperfReport(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		wantErr string
+		check   func(t *testing.T, report *IperfReport)
+		// loss is what ParseLoss returns for the UDP reports
+		loss *LossStats
+	}{
+		{
+			name:    "empty output",
+			output:  "  \n",
+			wantErr: "empty iperf3 output",
+		},
+		{
+			name:    "truncated json",
+			output:  `{"end": {"sum_received": {"seconds": 10`,
+			wantErr: "decode iperf3 json output",
+		},
+		{
+			name:    "not json",
+			output:  "iperf3: error - unable to connect to server: Connection refused",
+			wantErr: "decode iperf3 json output",
+		},
+		{
+			name:    "error field",
+			output:  `{"start": {}, "intervals": [], "end": {}, "error": "unable to connect to server: Connection refused"}`,
+			wantErr: "iperf3: unable to connect to server: Connection refused",
+		},
+		{
+			name:    "no summary",
+			output:  `{"intervals": [], "end": {}}`,
+			wantErr: "no summary",
+		},
+		{
+			name: "tcp over ipv4",
+			output: `{
+				"start": {"connected": [{"local_host": "10.0.0.1", "remote_host": "10.0.0.2"}]},
+				"intervals": [{"sum": {"start": 0, "end": 1, "seconds": 1, "bytes": 1250000000, "bits_per_second": 1e10}}],
+				"end": {
+					"sum_sent": {"seconds": 10, "bits_per_second": 9.4e9, "retransmits": 12},
+					"sum_received": {"seconds": 10, "bits_per_second": 9.3e9},
+					"cpu_utilization_percent": {"host_total": 35.5, "remote_total": 20.1}
+				}
+			}`,
+			check: func(t *testing.T, report *IperfReport) {
+				if len(report.Intervals) != 1 || report.Intervals[0].Sum.Bytes != 1250000000 {
+					t.Errorf("intervals = %+v", report.Intervals)
+				}
+				if report.End.SumSent.Retransmits != 12 || report.End.SumReceived.BitsPerSecond != 9.3e9 {
+					t.Errorf("end = %+v", report.End)
+				}
+				if report.End.CPUUtilizationPercent.HostTotal != 35.5 {
+					t.Errorf("cpu = %+v", report.End.CPUUtilizationPercent)
+				}
+			},
+		},
+		{
+			name: "udp over ipv6",
+			output: `{
+				"start": {"connected": [{"local_host": "fd00:1::1", "remote_host": "fd00:1::2"}]},
+				"intervals": [],
+				"end": {
+					"streams": [{"udp": {"out_of_order": 3}}],
+					"sum": {"seconds": 10, "jitter_ms": 0.02, "lost_packets": 5, "packets": 1000, "lost_percent": 0.5}
+				}
+			}`,
+			check: func(t *testing.T, report *IperfReport) {
+				sum := report.End.Sum
+				if sum.LostPackets != 5 || sum.Packets != 1000 || sum.LostPercent != 0.5 || sum.JitterMS != 0.02 {
+					t.Errorf("sum = %+v", sum)
+				}
+				if len(report.End.Streams) != 1 || report.End.Streams[0].UDP.OutOfOrder != 3 {
+					t.Errorf("streams = %+v", report.End.Streams)
+				}
+			},
+			// reordering is summed from the streams when the summary
+			// has none
+			loss: &LossStats{Packets: 1000, LostPackets: 5, LostPercent: 0.5, OutOfOrder: 3, JitterMS: 0.02},
+		},
+		{
+			name: "udp with reordering in the summary",
+			output: `{
+				"start": {"connected": [{"local_host": "192.168.1.1", "remote_host": "192.168.1.2"}]},
+				"intervals": [],
+				"end": {
+					"streams": [{"udp": {"out_of_order": 7}}],
+					"sum": {"seconds": 5, "bits_per_second": 5e8, "jitter_ms": 0.1, "lost_packets": 0, "packets": 43000, "lost_percent": 0, "out_of_order": 7}
+				}
+			}`,
+			check: func(t *testing.T, report *IperfReport) {},
+			loss:  &LossStats{Packets: 43000, OutOfOrder: 7, JitterMS: 0.1, BandwidthMB: BitsToMB(5e8)},
+		},
+		{
+			name:    "truncated udp report",
+			output:  `{"end": {"sum": {"seconds": 5, "lost_percent": 1.5, "packets": 4`,
+			wantErr: "decode iperf3 json output",
+		},
+		{
+			name:    "udp error report",
+			output:  `{"start": {}, "intervals": [], "end": {}, "error": "unable to read from stream socket: Resource temporarily unavailable"}`,
+			wantErr: "iperf3: unable to read from stream socket",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := ParseIperfReport(tt.output)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseIperfReport() error = %v, want %q", err, tt.wantErr)
+				}
+				if _, err := ParseLoss(tt.output); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseLoss() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseIperfReport() error = %v", err)
+			}
+			tt.check(t, report)
+			if tt.loss != nil {
+				loss, err := ParseLoss(tt.output)
+				if err != nil {
+					t.Fatalf("ParseLoss() error = %v", err)
+				}
+				if loss != *tt.loss {
+					t.Errorf("ParseLoss() = %+v, want %+v", loss, *tt.loss)
+				}
+			}
+		})
+	}
+}
+
+func TestBitsToMB(t *testing.T) {
+	if got := BitsToMB(8e9); got != 1000 {
+		t.Errorf("BitsToMB(8e9) = %v, want 1000", got)
+	}
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package network
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+)
+
+// PingCount is the number of echo requests sent to measure latency.
+const PingCount = 20
+
+// LatencyStats summarizes the round trip times of a ping run.
+type LatencyStats struct {
+	MinMS    float64 `json:"minMS"`
+	AvgMS    float64 `json:"avgMS"`
+	MaxMS    float64 `json:"maxMS"`
+	P99MS    float64 `json:"p99MS"`
+	JitterMS float64 `json:"jitterMS"`
+	Sent     int     `json:"sent"`
+	Received int     `json:"received"`
+}
+
+func (s LatencyStats) Measurements() []checker.Measurement {
+	return []checker.Measurement{
+		{Name: "rtt avg", Value: s.AvgMS, Unit: "ms"},
+		{Name: "rtt min", Value: s.MinMS, Unit: "ms"},
+		{Name: "rtt max", Value: s.MaxMS, Unit: "ms"},
+		{Name: "rtt p99", Value: s.P99MS, Unit: "ms"},
+		{Name: "jitter", Value: s.JitterMS, Unit: "ms"},
+	}
+}
+
+// PingCmd returns the command measuring the latency from srcIP to dstIP. An
+// empty srcIP lets the kernel pick the source address. The exit code of ping
+// is ignored, lost replies are detected by ParsePing.
+func PingCmd(srcIP, dstIP string) string {
+	if srcIP == "" {
+		return fmt.Sprintf("ping -n -c %d -i 0.2 -W 1 %s; true", PingCount, dstIP)
+	}
+	return fmt.Sprintf("ping -n -c %d -i 0.2 -W 1 -I %s %s; true", PingCount, srcIP, dstIP)
+}
+
+var (
+	pingReplyRegexp = regexp.MustCompile(`time=([0-9.]+) ms`)
+	pingStatsRegexp = regexp.MustCompile(`(\d+) packets transmitted, (\d+) (?:packets )?received`)
+)
+
+// ParsePing computes the latency statistics from the output of ping. Jitter
+// is the mean difference between consecutive round trip times.
+func ParsePing(output string) (LatencyStats, error) {
+	stats := LatencyStats{}
+	match := pingStatsRegexp.FindStringSubmatch(output)
+	if match == nil {
+		return stats, errors.New("no ping statistics in output")
+	}
+	stats.Sent, _ = strconv.Atoi(match[1])
+	stats.Received, _ = strconv.Atoi(match[2])
+
+	rtts := []float64{}
+	for _, reply := range pingReplyRegexp.FindAllStringSubmatch(output, -1) {
+		rtt, err := strconv.ParseFloat(reply[1], 64)
+		if err != nil {
+			return stats, fmt.Errorf("parse round trip time %s: %v", reply[1], err)
+		}
+		rtts = append(rtts, rtt)
+	}
+	if len(rtts) == 0 {
+		return stats, fmt.Errorf("no reply to %d echo requests", stats.Sent)
+	}
+
+	sum, jitter := 0.0, 0.0
+	for i, rtt := range rtts {
+		sum += rtt
+		if i > 0 {
+			jitter += math.Abs(rtt - rtts[i-1])
+		}
+	}
+	if len(rtts) > 1 {
+		stats.JitterMS = jitter / float64(len(rtts)-1)
+	}
+	stats.AvgMS = sum / float64(len(rtts))
+
+	sort.Float64s(rtts)
+	stats.MinMS = rtts[0]
+	stats.MaxMS = rtts[len(rtts)-1]
+	stats.P99MS = Percentile(rtts, 99)
+	return stats, nil
+}
+
+// Percentile returns the nearest-rank percentile of the sorted values.
+func Percentile(sorted []float64, percentile float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(percentile / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// LatencySubResult fills sr from the output of PingCmd. The pair is flagged
+// with a warning when the average round trip time exceeds maxLatencyMS.
+func LatencySubResult(sr checker.SubResult, output string, maxLatencyMS float64) checker.SubResult {
+	stats, err := ParsePing(output)
+	if err != nil {
+		sr.Status = checker.StatusFail
+		sr.Message = err.Error()
+		return sr
+	}
+	sr.Measurements = stats.Measurements()
+	sr.Details = stats
+	if stats.AvgMS > maxLatencyMS {
+		sr.Status = checker.StatusWarn
+		sr.Message = fmt.Sprintf("Average round trip time %.3fms exceeds %.2fms", stats.AvgMS, maxLatencyMS)
+	}
+	return sr
+}
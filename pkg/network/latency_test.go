@@ -0,0 +1,150 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package network
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParsePing(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    LatencyStats
+		wantErr string
+	}{
+		{
+			name:    "empty",
+			output:  "",
+			wantErr: "no ping statistics",
+		},
+		{
+			name:    "unknown host",
+			output:  "ping: 192.168.10.300: Name or service not known\n",
+			wantErr: "no ping statistics",
+		},
+		{
+			name: "all replies lost",
+			output: `PING 192.168.10.12 (192.168.10.12) 56(84) bytes of data.
+
+--- 192.168.10.12 ping statistics ---
+3 packets transmitted, 0 received, +3 errors, 100% packet loss, time 2040ms
+`,
+			wantErr: "no reply to 3 echo requests",
+		},
+		{
+			name: "iputils",
+			output: `PING 192.168.10.12 (192.168.10.12) from 192.168.10.11 : 56(84) bytes of data.
+64 bytes from 192.168.10.12: icmp_seq=1 ttl=64 time=0.300 ms
+64 bytes from 192.168.10.12: icmp_seq=2 ttl=64 time=0.100 ms
+64 bytes from 192.168.10.12: icmp_seq=3 ttl=64 time=0.200 ms
+64 bytes from 192.168.10.12: icmp_seq=4 ttl=64 time=0.400 ms
+
+--- 192.168.10.12 ping statistics ---
+4 packets transmitted, 4 received, 0% packet loss, time 603ms
+rtt min/avg/max/mdev = 0.100/0.250/0.400/0.111 ms
+`,
+			// jitter is (0.2 + 0.1 + 0.2) / 3
+			want: LatencyStats{MinMS: 0.1, AvgMS: 0.25, MaxMS: 0.4, P99MS: 0.4, JitterMS: 0.5 / 3, Sent: 4, Received: 4},
+		},
+		{
+			name: "busybox packets received",
+			output: `PING 10.244.1.5 (10.244.1.5): 56 data bytes
+64 bytes from 10.244.1.5: seq=0 ttl=62 time=1.000 ms
+64 bytes from 10.244.1.5: seq=1 ttl=62 time=3.000 ms
+
+--- 10.244.1.5 ping statistics ---
+2 packets transmitted, 2 packets received, 0% packet loss
+round-trip min/avg/max = 1.000/2.000/3.000 ms
+`,
+			want: LatencyStats{MinMS: 1, AvgMS: 2, MaxMS: 3, P99MS: 3, JitterMS: 2, Sent: 2, Received: 2},
+		},
+		{
+			name: "lost replies over IPv6",
+			output: `PING fd00:10::12(fd00:10::12) from fd00:10::11 : 56 data bytes
+64 bytes from fd00:10::12: icmp_seq=1 ttl=64 time=0.500 ms
+64 bytes from fd00:10::12: icmp_seq=3 ttl=64 time=0.700 ms
+
+--- fd00:10::12 ping statistics ---
+3 packets transmitted, 2 received, 33.3333% packet loss, time 2030ms
+rtt min/avg/max/mdev = 0.500/0.600/0.700/0.100 ms
+`,
+			want: LatencyStats{MinMS: 0.5, AvgMS: 0.6, MaxMS: 0.7, P99MS: 0.7, JitterMS: 0.2, Sent: 3, Received: 2},
+		},
+		{
+			name: "single reply has no jitter",
+			output: `64 bytes from 192.168.10.12: icmp_seq=1 ttl=64 time=0.250 ms
+1 packets transmitted, 1 received, 0% packet loss, time 0ms
+`,
+			want: LatencyStats{MinMS: 0.25, AvgMS: 0.25, MaxMS: 0.25, P99MS: 0.25, Sent: 1, Received: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePing(tt.output)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !latencyStatsEqual(got, tt.want) {
+				t.Errorf("ParsePing() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func latencyStatsEqual(a, b LatencyStats) bool {
+	near := func(x, y float64) bool {
+		return math.Abs(x-y) < 1e-9
+	}
+	return near(a.MinMS, b.MinMS) && near(a.AvgMS, b.AvgMS) && near(a.MaxMS, b.MaxMS) &&
+		near(a.P99MS, b.P99MS) && near(a.JitterMS, b.JitterMS) &&
+		a.Sent == b.Sent && a.Received == b.Received
+}
+
+func TestPercentile(t *testing.T) {
+	twenty := []float64{}
+	for i := 1; i <= 20; i++ {
+		twenty = append(twenty, float64(i))
+	}
+	tests := []struct {
+		sorted     []float64
+		percentile float64
+		want       float64
+	}{
+		{nil, 99, 0},
+		{[]float64{0.3}, 99, 0.3},
+		{[]float64{0.3}, 0, 0.3},
+		{[]float64{1, 2, 3}, 99, 3},
+		{[]float64{1, 2, 3}, 50, 2},
+		{[]float64{1, 2, 3, 4}, 50, 2},
+		{[]float64{1, 2, 3, 4}, 0, 1},
+		{twenty, 99, 20},
+		{twenty, 95, 19},
+		{twenty, 100, 20},
+	}
+	for i, tt := range tests {
+		if got := Percentile(tt.sorted, tt.percentile); got != tt.want {
+			t.Errorf("test %d: Percentile(%v, %v) = %v, want %v", i, tt.sorted, tt.percentile, got, tt.want)
+		}
+	}
+}
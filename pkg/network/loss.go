@@ -0,0 +1,99 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package network
+
+import (
+	"fmt"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+	"github.com/iomesh/debugtool/pkg/config"
+)
+
+// LossStats is the outcome of a UDP iperf3 test sending at a fixed rate.
+type LossStats struct {
+	Packets     int     `json:"packets"`
+	LostPackets int     `json:"lostPackets"`
+	LostPercent float64 `json:"lostPercent"`
+	OutOfOrder  int     `json:"outOfOrder"`
+	JitterMS    float64 `json:"jitterMS"`
+	BandwidthMB float64 `json:"bandwidthMB"`
+}
+
+func (s LossStats) Measurements() []checker.Measurement {
+	return []checker.Measurement{
+		{Name: "loss", Value: s.LostPercent, Unit: "%"},
+		{Name: "out of order", Value: float64(s.OutOfOrder)},
+	}
+}
+
+// UDPLossCmd returns the command sending UDP traffic at the given rate, in
+// iperf3 -b syntax such as 500M, to the iperf3 server at serverIP. A non
+// empty bindIP selects the source address.
+func UDPLossCmd(bindIP, serverIP, rate string) string {
+	bind := ""
+	if bindIP != "" {
+		bind = "-B " + bindIP + " "
+	}
+	return fmt.Sprintf("iperf3 -J -u -b %s -t 5 %s-c %s; true", rate, bind, serverIP)
+}
+
+// ParseLoss decodes the output of UDPLossCmd. The loss is measured by the
+// server and sent back to the client at the end of the test.
+func ParseLoss(output string) (LossStats, error) {
+	report, err := ParseIperfReport(output)
+	if err != nil {
+		return LossStats{}, err
+	}
+	sum := report.End.Sum
+	stats := LossStats{
+		Packets:     sum.Packets,
+		LostPackets: sum.LostPackets,
+		LostPercent: sum.LostPercent,
+		OutOfOrder:  sum.OutOfOrder,
+		JitterMS:    sum.JitterMS,
+		BandwidthMB: BitsToMB(sum.BitsPerSecond),
+	}
+	// older iperf3 releases only report reordering per stream
+	if stats.OutOfOrder == 0 {
+		for _, stream := range report.End.Streams {
+			stats.OutOfOrder += stream.UDP.OutOfOrder
+		}
+	}
+	return stats, nil
+}
+
+// LossSubResult fills sr from the output of UDPLossCmd and grades the loss
+// against the configured thresholds.
+func LossSubResult(sr checker.SubResult, output string) checker.SubResult {
+	stats, err := ParseLoss(output)
+	if err != nil {
+		sr.Status = checker.StatusFail
+		sr.Message = err.Error()
+		return sr
+	}
+	sr.Measurements = stats.Measurements()
+	sr.Details = stats
+
+	cfg := config.Get()
+	switch {
+	case stats.LostPercent > cfg.MaxLossPercent:
+		sr.Status = checker.StatusFail
+		sr.Message = fmt.Sprintf("%.2f%% packets lost at %s, more than %.2f%%", stats.LostPercent, cfg.LossRate, cfg.MaxLossPercent)
+	case stats.LostPercent > cfg.WarnLossPercent:
+		sr.Status = checker.StatusWarn
+		sr.Message = fmt.Sprintf("%.2f%% packets lost at %s, more than %.2f%%", stats.LostPercent, cfg.LossRate, cfg.WarnLossPercent)
+	}
+	return sr
+}
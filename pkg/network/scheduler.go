@@ -0,0 +1,106 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package network
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+)
+
+// Pair is an ordered pair of node indexes, the client measures against the
+// server.
+type Pair struct {
+	Client int
+	Server int
+}
+
+// Schedule splits every ordered pair of n nodes into rounds. Within a round a
+// node takes part in at most one test, either as client or as server, so the
+// tests of a round can run concurrently without disturbing each other.
+//
+// The unordered pairs are arranged with the circle method of round-robin
+// tournaments, every round is then run once in each direction.
+func Schedule(n int) [][]Pair {
+	if n < 2 {
+		return nil
+	}
+
+	// with an odd number of nodes one node sits out every round, paired
+	// with the placeholder index n
+	slots := n
+	if slots%2 == 1 {
+		slots++
+	}
+	circle := make([]int, slots)
+	for i := range circle {
+		circle[i] = i
+	}
+
+	rounds := [][]Pair{}
+	for round := 0; round < slots-1; round++ {
+		forward := []Pair{}
+		backward := []Pair{}
+		for i := 0; i < slots/2; i++ {
+			a, b := circle[i], circle[slots-1-i]
+			if a >= n || b >= n {
+				continue
+			}
+			forward = append(forward, Pair{Client: a, Server: b})
+			backward = append(backward, Pair{Client: b, Server: a})
+		}
+		rounds = append(rounds, forward, backward)
+
+		// keep the first slot fixed and rotate the others
+		last := circle[slots-1]
+		copy(circle[2:], circle[1:slots-1])
+		circle[1] = last
+	}
+	return rounds
+}
+
+// RunScheduled calls measure for every ordered pair of n nodes. The pairs of
+// a round run concurrently, rounds run one after the other.
+func RunScheduled(n int, measure func(pair Pair)) {
+	for _, round := range Schedule(n) {
+		var wg sync.WaitGroup
+		for _, pair := range round {
+			wg.Add(1)
+			go func(pair Pair) {
+				defer wg.Done()
+				measure(pair)
+			}(pair)
+		}
+		wg.Wait()
+	}
+}
+
+// MeasurePairs runs measure for every ordered pair of n nodes like
+// RunScheduled and returns the sub-results ordered by name.
+func MeasurePairs(n int, measure func(pair Pair) checker.SubResult) []checker.SubResult {
+	subResults := []checker.SubResult{}
+	var lock sync.Mutex
+	RunScheduled(n, func(pair Pair) {
+		sr := measure(pair)
+		lock.Lock()
+		subResults = append(subResults, sr)
+		lock.Unlock()
+	})
+	sort.Slice(subResults, func(i, j int) bool {
+		return subResults[i].Name() < subResults[j].Name()
+	})
+	return subResults
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package network
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+)
+
+func TestSchedule(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 3, 4, 5, 8, 11} {
+		t.Run(fmt.Sprintf("%d nodes", n), func(t *testing.T) {
+			rounds := Schedule(n)
+			if n < 2 {
+				if len(rounds) != 0 {
+					t.Fatalf("Schedule(%d) = %v, want no round", n, rounds)
+				}
+				return
+			}
+
+			seen := map[Pair]int{}
+			for r, round := range rounds {
+				busy := map[int]bool{}
+				for _, pair := range round {
+					if pair.Client == pair.Server {
+						t.Errorf("round %d pairs node %d with itself", r, pair.Client)
+					}
+					if pair.Client < 0 || pair.Client >= n || pair.Server < 0 || pair.Server >= n {
+						t.Errorf("round %d has out of range pair %+v", r, pair)
+					}
+					if busy[pair.Client] || busy[pair.Server] {
+						t.Errorf("round %d uses a node twice: %v", r, round)
+					}
+					busy[pair.Client], busy[pair.Server] = true, true
+					seen[pair]++
+				}
+			}
+			for client := 0; client < n; client++ {
+				for server := 0; server < n; server++ {
+					if client == server {
+						continue
+					}
+					if count := seen[Pair{Client: client, Server: server}]; count != 1 {
+						t.Errorf("pair %d -> %d scheduled %d times, want once", client, server, count)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestMeasurePairs(t *testing.T) {
+	if got := MeasurePairs(0, nil); len(got) != 0 {
+		t.Errorf("MeasurePairs(0) = %v, want none", got)
+	}
+
+	var lock sync.Mutex
+	calls := 0
+	got := MeasurePairs(3, func(pair Pair) checker.SubResult {
+		lock.Lock()
+		calls++
+		lock.Unlock()
+		return checker.SubResult{
+			Node: fmt.Sprintf("node%d", pair.Client),
+			Peer: fmt.Sprintf("node%d", pair.Server),
+		}
+	})
+	if calls != 6 || len(got) != 6 {
+		t.Fatalf("MeasurePairs(3) measured %d pairs and returned %d, want 6", calls, len(got))
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i-1].Name() >= got[i].Name() {
+			t.Errorf("sub-results not ordered by name: %q before %q", got[i-1].Name(), got[i].Name())
+		}
+	}
+}
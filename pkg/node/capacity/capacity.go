@@ -0,0 +1,161 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+	"github.com/iomesh/debugtool/pkg/config"
+	"github.com/iomesh/debugtool/pkg/constant"
+)
+
+// MinStorageNodes is how many nodes an IOMesh cluster needs to keep three
+// replicas of its meta service.
+const MinStorageNodes = 3
+
+type CapacityChecker struct {
+	checker.Checker
+}
+
+func NewCapacityChecker() *CapacityChecker {
+	return &CapacityChecker{
+		Checker: checker.Newchecker("CapacityChecker"),
+	}
+}
+
+func init() {
+	checker.Register(NewCapacityChecker())
+}
+
+func (cc CapacityChecker) Name() string {
+	return constant.NodeCapacityCheckName
+}
+
+func (cc CapacityChecker) Category() checker.Category {
+	return checker.CategoryNode
+}
+
+func (cc CapacityChecker) Dependencies() []string {
+	return nil
+}
+
+func (cc CapacityChecker) Description() string {
+	return "Checking node resources left for IOMesh"
+}
+
+func (cc CapacityChecker) Run(ctx context.Context) checker.Result {
+	result := checker.Result{
+		Status:      checker.StatusPass,
+		Remediation: "Add CPU, memory or hugepages to the storage nodes, move workloads away from them, or pick a smaller --deployment-size",
+	}
+	cfg := config.Get()
+	footprint, err := LoadFootprint(cfg.DeploymentSize, cfg.FootprintFile)
+	if err != nil {
+		result.Failf("%v", err)
+		return result
+	}
+
+	nodes := &corev1.NodeList{}
+	if err := cc.Client.List(ctx, nodes); err != nil {
+		result.Failf("List nodes: %v", err)
+		return result
+	}
+	pods := &corev1.PodList{}
+	if err := cc.Client.List(ctx, pods); err != nil {
+		result.Failf("List pods: %v", err)
+		return result
+	}
+	podsByNode := map[string][]corev1.Pod{}
+	for _, pod := range pods.Items {
+		// the debug pods go away once the checks are done
+		if pod.Spec.NodeName == "" || pod.Namespace == constant.DebugNamespace ||
+			pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		podsByNode[pod.Spec.NodeName] = append(podsByNode[pod.Spec.NodeName], pod)
+	}
+
+	sort.Slice(nodes.Items, func(i, j int) bool {
+		return nodes.Items[i].Name < nodes.Items[j].Name
+	})
+	fitting, unschedulable := 0, 0
+	tooSmall := []string{}
+	for _, node := range nodes.Items {
+		headroom := Headroom(node.Status.Allocatable, podsByNode[node.Name])
+		sr := checker.SubResult{
+			Node:   node.Name,
+			Status: checker.StatusPass,
+			Measurements: []checker.Measurement{
+				{Name: "free cpu", Value: float64(headroom.Cpu().MilliValue()) / 1000, Unit: "cores"},
+				{Name: "free memory", Value: float64(headroom.Memory().Value()) / (1 << 30), Unit: "GiB"},
+			},
+		}
+		// nodes IOMesh can't be scheduled on, such as tainted control
+		// plane nodes, are only listed, they don't count either way
+		if reason := unschedulableReason(node); reason != "" {
+			unschedulable++
+			sr.Message = "Ignored, " + reason
+			result.AddSubResult(sr)
+			continue
+		}
+		if shortfalls := Shortfalls(headroom, footprint); len(shortfalls) > 0 {
+			tooSmall = append(tooSmall, node.Name)
+			sr.Status = checker.StatusWarn
+			sr.Message = "Can't host IOMesh: " + strings.Join(shortfalls, ", ")
+		} else {
+			fitting++
+			sr.Message = fmt.Sprintf("Fits the %s footprint", cfg.DeploymentSize)
+		}
+		result.AddSubResult(sr)
+	}
+
+	// the status is set from the counts: IOMesh can't be installed on fewer
+	// than MinStorageNodes fitting nodes, other nodes that are too small
+	// only warn
+	switch {
+	case fitting == 0:
+		result.Failf("No node has room for a %s IOMesh deployment", cfg.DeploymentSize)
+	case fitting < MinStorageNodes:
+		result.Failf("Only %d nodes can host a %s IOMesh deployment, at least %d are needed", fitting, cfg.DeploymentSize, MinStorageNodes)
+	case len(tooSmall) > 0:
+		result.Warnf("%d nodes can host a %s IOMesh deployment, nodes %v can't", fitting, cfg.DeploymentSize, strings.Join(tooSmall, ", "))
+	default:
+		result.Message = fmt.Sprintf("%d nodes can host a %s IOMesh deployment", fitting, cfg.DeploymentSize)
+	}
+	if unschedulable > 0 {
+		result.Message += fmt.Sprintf(", %d unschedulable nodes ignored", unschedulable)
+	}
+	return result
+}
+
+// unschedulableReason tells why pods without tolerations can't run on a node,
+// empty when they can.
+func unschedulableReason(node corev1.Node) string {
+	if node.Spec.Unschedulable {
+		return "cordoned"
+	}
+	for _, taint := range node.Spec.Taints {
+		if taint.Effect == corev1.TaintEffectNoSchedule || taint.Effect == corev1.TaintEffectNoExecute {
+			return fmt.Sprintf("tainted %s:%s", taint.Key, taint.Effect)
+		}
+	}
+	return ""
+}
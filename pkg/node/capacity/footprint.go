@@ -0,0 +1,145 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultFootprints are the resources the meta and chunk pods of IOMesh
+// request on every storage node, by deployment size.
+var DefaultFootprints = map[string]corev1.ResourceList{
+	"small": {
+		corev1.ResourceCPU:                   resource.MustParse("3"),
+		corev1.ResourceMemory:                resource.MustParse("6Gi"),
+		corev1.ResourceName("hugepages-2Mi"): resource.MustParse("1Gi"),
+	},
+	"medium": {
+		corev1.ResourceCPU:                   resource.MustParse("6"),
+		corev1.ResourceMemory:                resource.MustParse("12Gi"),
+		corev1.ResourceName("hugepages-2Mi"): resource.MustParse("2Gi"),
+	},
+	"large": {
+		corev1.ResourceCPU:                   resource.MustParse("10"),
+		corev1.ResourceMemory:                resource.MustParse("24Gi"),
+		corev1.ResourceName("hugepages-2Mi"): resource.MustParse("4Gi"),
+	},
+}
+
+// LoadFootprint returns the footprint of a deployment size, looked up in the
+// YAML file at path before the built-in footprints. The file maps deployment
+// sizes to resource lists, such as:
+//
+//	small:
+//	  cpu: "4"
+//	  memory: 8Gi
+func LoadFootprint(size, path string) (corev1.ResourceList, error) {
+	footprints := map[string]corev1.ResourceList{}
+	for name, footprint := range DefaultFootprints {
+		footprints[name] = footprint
+	}
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("Read footprint file: %v", err)
+		}
+		custom := map[string]corev1.ResourceList{}
+		if err := yaml.UnmarshalStrict(data, &custom); err != nil {
+			return nil, fmt.Errorf("Parse footprint file %v: %v", path, err)
+		}
+		for name, footprint := range custom {
+			footprints[name] = footprint
+		}
+	}
+	footprint, ok := footprints[size]
+	if !ok {
+		sizes := []string{}
+		for name := range footprints {
+			sizes = append(sizes, name)
+		}
+		sort.Strings(sizes)
+		return nil, fmt.Errorf("Unknown deployment size %q, expected one of %v", size, strings.Join(sizes, ", "))
+	}
+	return footprint, nil
+}
+
+// PodRequests returns the resources the scheduler reserves for a pod, the
+// larger of the sum of its containers and of each init container, plus its
+// overhead.
+func PodRequests(pod corev1.Pod) corev1.ResourceList {
+	requests := corev1.ResourceList{}
+	for _, container := range pod.Spec.Containers {
+		addResources(requests, container.Resources.Requests)
+	}
+	for _, container := range pod.Spec.InitContainers {
+		for name, quantity := range container.Resources.Requests {
+			if current, ok := requests[name]; !ok || quantity.Cmp(current) > 0 {
+				requests[name] = quantity.DeepCopy()
+			}
+		}
+	}
+	addResources(requests, pod.Spec.Overhead)
+	return requests
+}
+
+func addResources(total, resources corev1.ResourceList) {
+	for name, quantity := range resources {
+		current := total[name]
+		current.Add(quantity)
+		total[name] = current
+	}
+}
+
+// Headroom is the allocatable resources of a node minus the requests of its
+// pods.
+func Headroom(allocatable corev1.ResourceList, pods []corev1.Pod) corev1.ResourceList {
+	headroom := corev1.ResourceList{}
+	for name, quantity := range allocatable {
+		headroom[name] = quantity.DeepCopy()
+	}
+	for _, pod := range pods {
+		for name, quantity := range PodRequests(pod) {
+			current := headroom[name]
+			current.Sub(quantity)
+			headroom[name] = current
+		}
+	}
+	return headroom
+}
+
+// Shortfalls lists the resources of the footprint the headroom can't hold.
+func Shortfalls(headroom, footprint corev1.ResourceList) []string {
+	names := []string{}
+	for name := range footprint {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	shortfalls := []string{}
+	for _, name := range names {
+		needed := footprint[corev1.ResourceName(name)]
+		free := headroom[corev1.ResourceName(name)]
+		if free.Cmp(needed) < 0 {
+			shortfalls = append(shortfalls, fmt.Sprintf("%s %s free, %s needed", name, free.String(), needed.String()))
+		}
+	}
+	return shortfalls
+}
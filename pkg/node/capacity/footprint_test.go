@@ -0,0 +1,234 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func resources(pairs ...string) corev1.ResourceList {
+	list := corev1.ResourceList{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		list[corev1.ResourceName(pairs[i])] = resource.MustParse(pairs[i+1])
+	}
+	return list
+}
+
+func container(pairs ...string) corev1.Container {
+	return corev1.Container{Resources: corev1.ResourceRequirements{Requests: resources(pairs...)}}
+}
+
+// equalResources compares resource lists by value, 1 and 1000m are equal.
+func equalResources(a, b corev1.ResourceList) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, quantity := range a {
+		other, ok := b[name]
+		if !ok || quantity.Cmp(other) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPodRequests(t *testing.T) {
+	tests := []struct {
+		name string
+		spec corev1.PodSpec
+		want corev1.ResourceList
+	}{
+		{
+			name: "no requests",
+			spec: corev1.PodSpec{Containers: []corev1.Container{{}}},
+			want: resources(),
+		},
+		{
+			name: "containers are summed",
+			spec: corev1.PodSpec{Containers: []corev1.Container{
+				container("cpu", "500m", "memory", "1Gi"),
+				container("cpu", "1", "hugepages-2Mi", "512Mi"),
+			}},
+			want: resources("cpu", "1500m", "memory", "1Gi", "hugepages-2Mi", "512Mi"),
+		},
+		{
+			// init containers run one after the other before the
+			// containers, the largest of each resource counts
+			name: "init containers take the max",
+			spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{
+					container("cpu", "2", "memory", "256Mi"),
+					container("cpu", "1", "memory", "3Gi"),
+				},
+				Containers: []corev1.Container{
+					container("cpu", "500m", "memory", "1Gi"),
+					container("cpu", "500m", "memory", "1Gi"),
+				},
+			},
+			want: resources("cpu", "2", "memory", "3Gi"),
+		},
+		{
+			name: "init container only resource",
+			spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{container("ephemeral-storage", "1Gi")},
+				Containers:     []corev1.Container{container("cpu", "100m")},
+			},
+			want: resources("cpu", "100m", "ephemeral-storage", "1Gi"),
+		},
+		{
+			name: "overhead is added",
+			spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{container("cpu", "2")},
+				Containers:     []corev1.Container{container("cpu", "500m", "memory", "1Gi")},
+				Overhead:       resources("cpu", "250m", "memory", "120Mi"),
+			},
+			want: resources("cpu", "2250m", "memory", "1144Mi"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PodRequests(corev1.Pod{Spec: tt.spec})
+			if !equalResources(got, tt.want) {
+				t.Errorf("PodRequests() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeadroom(t *testing.T) {
+	allocatable := resources("cpu", "8", "memory", "32Gi", "pods", "110")
+	pods := []corev1.Pod{
+		{Spec: corev1.PodSpec{Containers: []corev1.Container{container("cpu", "1500m", "memory", "4Gi")}}},
+		{Spec: corev1.PodSpec{Containers: []corev1.Container{container("cpu", "500m", "hugepages-2Mi", "1Gi")}}},
+	}
+	tests := []struct {
+		name string
+		pods []corev1.Pod
+		want corev1.ResourceList
+	}{
+		{name: "no pod", want: allocatable},
+		{
+			name: "pods",
+			pods: pods,
+			// hugepages the node doesn't have end up negative
+			want: resources("cpu", "6", "memory", "28Gi", "pods", "110", "hugepages-2Mi", "-1Gi"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Headroom(allocatable, tt.pods)
+			if !equalResources(got, tt.want) {
+				t.Errorf("Headroom() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if cpu := allocatable[corev1.ResourceCPU]; cpu.Cmp(resource.MustParse("8")) != 0 {
+		t.Errorf("Headroom modified allocatable cpu to %v", cpu.String())
+	}
+}
+
+func TestShortfalls(t *testing.T) {
+	footprint := DefaultFootprints["small"]
+	tests := []struct {
+		name     string
+		headroom corev1.ResourceList
+		want     []string
+	}{
+		{
+			name:     "fits",
+			headroom: resources("cpu", "4", "memory", "8Gi", "hugepages-2Mi", "1Gi"),
+			want:     []string{},
+		},
+		{
+			name:     "exactly fits",
+			headroom: resources("cpu", "3000m", "memory", "6Gi", "hugepages-2Mi", "1024Mi"),
+			want:     []string{},
+		},
+		{
+			name:     "no hugepages allocatable",
+			headroom: resources("cpu", "4", "memory", "8Gi"),
+			want:     []string{"hugepages-2Mi 0 free, 1Gi needed"},
+		},
+		{
+			name:     "nothing allocatable",
+			headroom: resources(),
+			want:     []string{"cpu 0 free, 3 needed", "hugepages-2Mi 0 free, 1Gi needed", "memory 0 free, 6Gi needed"},
+		},
+		{
+			name:     "overcommitted",
+			headroom: resources("cpu", "-500m", "memory", "2Gi", "hugepages-2Mi", "1Gi"),
+			want:     []string{"cpu -500m free, 3 needed", "memory 2Gi free, 6Gi needed"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Shortfalls(tt.headroom, footprint); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Shortfalls() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadFootprint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "footprint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "footprint.yaml")
+	if err := ioutil.WriteFile(path, []byte("small:\n  cpu: \"4\"\n  memory: 8Gi\ntiny:\n  cpu: \"1\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	malformed := filepath.Join(dir, "malformed.yaml")
+	if err := ioutil.WriteFile(malformed, []byte("small: [cpu"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		size, path string
+		want       corev1.ResourceList
+		wantErr    string
+	}{
+		{size: "medium", want: DefaultFootprints["medium"]},
+		{size: "huge", wantErr: `Unknown deployment size "huge", expected one of large, medium, small`},
+		{size: "small", path: path, want: resources("cpu", "4", "memory", "8Gi")},
+		{size: "tiny", path: path, want: resources("cpu", "1")},
+		{size: "large", path: path, want: DefaultFootprints["large"]},
+		{size: "small", path: malformed, wantErr: "Parse footprint file"},
+		{size: "small", path: filepath.Join(dir, "missing.yaml"), wantErr: "Read footprint file"},
+	}
+	for i, tt := range tests {
+		got, err := LoadFootprint(tt.size, tt.path)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("test %d: err = %v, want %q", i, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: unexpected error %v", i, err)
+		} else if !equalResources(got, tt.want) {
+			t.Errorf("test %d: LoadFootprint(%q) = %v, want %v", i, tt.size, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package compatibility
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+	"github.com/iomesh/debugtool/pkg/config"
+	"github.com/iomesh/debugtool/pkg/constant"
+	"github.com/iomesh/debugtool/pkg/fixture"
+)
+
+// readHostOSCmd prints the os-release of the host and the filesystem type of
+// its cgroup mount, cgroup2fs on the unified hierarchy.
+const readHostOSCmd = `H="nsenter -t 1 -m --"
+$H cat /etc/os-release
+echo CGROUP_FS=$($H stat -fc %T /sys/fs/cgroup)`
+
+// NodeOS is what a node runs, as reported by the kubelet and read on the host.
+type NodeOS struct {
+	Node                    string `json:"node"`
+	Architecture            string `json:"architecture"`
+	KernelVersion           string `json:"kernelVersion"`
+	OSImage                 string `json:"osImage"`
+	DistributionID          string `json:"distributionID,omitempty"`
+	DistributionVersion     string `json:"distributionVersion,omitempty"`
+	CgroupVersion           string `json:"cgroupVersion,omitempty"`
+	ContainerRuntime        string `json:"containerRuntime"`
+	ContainerRuntimeVersion string `json:"containerRuntimeVersion"`
+}
+
+func (o NodeOS) String() string {
+	facts := []string{o.OSImage, "kernel " + o.KernelVersion, o.Architecture}
+	if o.CgroupVersion != "" {
+		facts = append(facts, "cgroup "+o.CgroupVersion)
+	}
+	facts = append(facts, o.ContainerRuntime+" "+o.ContainerRuntimeVersion)
+	return strings.Join(facts, ", ")
+}
+
+// NewNodeOS fills a NodeOS from the node info the kubelet reports.
+func NewNodeOS(node corev1.Node) NodeOS {
+	info := node.Status.NodeInfo
+	o := NodeOS{
+		Node:          node.Name,
+		Architecture:  info.Architecture,
+		KernelVersion: info.KernelVersion,
+		OSImage:       info.OSImage,
+	}
+	// such as containerd://1.6.8 or docker://20.10.7
+	parts := strings.SplitN(info.ContainerRuntimeVersion, "://", 2)
+	o.ContainerRuntime = parts[0]
+	if len(parts) == 2 {
+		o.ContainerRuntimeVersion = parts[1]
+	}
+	return o
+}
+
+// ParseHostOS fills the distribution and cgroup version of a NodeOS from the
+// output of readHostOSCmd.
+func ParseHostOS(o *NodeOS, output string) {
+	for _, line := range strings.Split(output, "\n") {
+		kv := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		value := strings.Trim(strings.TrimSpace(kv[1]), `"'`)
+		switch kv[0] {
+		case "ID":
+			o.DistributionID = value
+		case "VERSION_ID":
+			o.DistributionVersion = value
+		case "CGROUP_FS":
+			switch value {
+			case "cgroup2fs":
+				o.CgroupVersion = "v2"
+			case "tmpfs":
+				o.CgroupVersion = "v1"
+			}
+		}
+	}
+}
+
+// Evaluate returns what makes a node unsupported by the matrix, and what the
+// matrix does not know about.
+func (m Matrix) Evaluate(o NodeOS) (problems []string, warnings []string) {
+	if len(m.Architectures) > 0 && !contains(m.Architectures, o.Architecture) {
+		problems = append(problems, fmt.Sprintf("architecture %v is not one of %v", o.Architecture, strings.Join(m.Architectures, ", ")))
+	}
+
+	minKernel := m.MinKernelVersion
+	switch distribution, ok := m.Distribution(o.DistributionID); {
+	case o.DistributionID == "":
+		warnings = append(warnings, "distribution unknown")
+	case !ok:
+		warnings = append(warnings, fmt.Sprintf("distribution %v %v is not in the compatibility matrix", o.DistributionID, o.DistributionVersion))
+	default:
+		if !atLeast(o.DistributionVersion, distribution.MinVersion) {
+			problems = append(problems, fmt.Sprintf("%v %v is older than %v", o.DistributionID, o.DistributionVersion, distribution.MinVersion))
+		}
+		if distribution.MinKernelVersion != "" {
+			minKernel = distribution.MinKernelVersion
+		}
+	}
+	if !atLeast(o.KernelVersion, minKernel) {
+		problems = append(problems, fmt.Sprintf("kernel %v is older than %v", o.KernelVersion, minKernel))
+	}
+
+	switch {
+	case o.CgroupVersion == "":
+		warnings = append(warnings, "cgroup version unknown")
+	case len(m.CgroupVersions) > 0 && !contains(m.CgroupVersions, o.CgroupVersion):
+		problems = append(problems, fmt.Sprintf("cgroup %v is not one of %v", o.CgroupVersion, strings.Join(m.CgroupVersions, ", ")))
+	}
+
+	if runtime, ok := m.ContainerRuntime(o.ContainerRuntime); !ok {
+		problems = append(problems, fmt.Sprintf("container runtime %v is not supported", o.ContainerRuntime))
+	} else if !atLeast(o.ContainerRuntimeVersion, runtime.MinVersion) {
+		problems = append(problems, fmt.Sprintf("%v %v is older than %v", o.ContainerRuntime, o.ContainerRuntimeVersion, runtime.MinVersion))
+	}
+	return problems, warnings
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+type CompatibilityChecker struct {
+	checker.Checker
+}
+
+func NewCompatibilityChecker() *CompatibilityChecker {
+	return &CompatibilityChecker{
+		Checker: checker.Newchecker("CompatibilityChecker"),
+	}
+}
+
+func init() {
+	checker.Register(NewCompatibilityChecker())
+}
+
+func (cc CompatibilityChecker) Name() string {
+	return constant.OSCompatibilityCheckName
+}
+
+func (cc CompatibilityChecker) Category() checker.Category {
+	return checker.CategoryNode
+}
+
+func (cc CompatibilityChecker) Dependencies() []string {
+	return nil
+}
+
+func (cc CompatibilityChecker) Description() string {
+	return "Checking node OS and kernel compatibility"
+}
+
+func (cc CompatibilityChecker) Run(ctx context.Context) checker.Result {
+	result := checker.Result{
+		Status:      checker.StatusPass,
+		Remediation: "Run IOMesh on the distributions, kernels and container runtimes of the compatibility matrix, or upgrade the flagged nodes",
+	}
+	matrix, err := LoadMatrix(config.Get().CompatibilityFile)
+	if err != nil {
+		result.Failf("%v", err)
+		return result
+	}
+
+	nodes := &corev1.NodeList{}
+	if err := cc.Client.List(ctx, nodes); err != nil {
+		result.Failf("List nodes: %v", err)
+		return result
+	}
+	pods, err := fixture.GetInstance().NodeCheckerPods(ctx)
+	if err != nil {
+		result.Failf("%v", err)
+		return result
+	}
+	podByNode := map[string]string{}
+	for _, pod := range pods {
+		podByNode[pod.Spec.NodeName] = pod.Name
+	}
+
+	for _, node := range nodes.Items {
+		o := NewNodeOS(node)
+		if pod, ok := podByNode[node.Name]; ok {
+			output, err := cc.RunCmdInPod(pod, constant.DebugNamespace, readHostOSCmd)
+			if err != nil {
+				cc.Log.Error(err, "Read host OS fail", "pod", pod)
+			}
+			ParseHostOS(&o, output)
+		}
+
+		problems, warnings := matrix.Evaluate(o)
+		sr := checker.SubResult{
+			Node:    node.Name,
+			Status:  checker.StatusPass,
+			Message: o.String(),
+			Details: o,
+		}
+		switch {
+		case len(problems) > 0:
+			sr.Status = checker.StatusFail
+			sr.Message += ": " + strings.Join(problems, "; ")
+		case len(warnings) > 0:
+			sr.Status = checker.StatusWarn
+			sr.Message += ": " + strings.Join(warnings, "; ")
+		}
+		result.AddSubResult(sr)
+	}
+
+	if failed := result.Count(checker.StatusFail); failed > 0 {
+		result.Failf("%d nodes are not compatible with IOMesh", failed)
+	} else if warned := result.Count(checker.StatusWarn); warned > 0 {
+		result.Warnf("%d nodes run an OS outside of the compatibility matrix", warned)
+	} else {
+		result.Message = fmt.Sprintf("%d nodes are compatible with IOMesh", len(nodes.Items))
+	}
+	return result
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package compatibility
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Distribution is a Linux distribution supported from a minimum release.
+type Distribution struct {
+	// ID is the ID field of /etc/os-release, such as centos or ubuntu.
+	ID         string `json:"id"`
+	MinVersion string `json:"minVersion,omitempty"`
+	// MinKernelVersion overrides the kernel version required by the
+	// matrix on this distribution, whose vendor kernels backport features.
+	MinKernelVersion string `json:"minKernelVersion,omitempty"`
+}
+
+// ContainerRuntime is a container runtime supported from a minimum version.
+type ContainerRuntime struct {
+	// Name is the scheme of the container runtime version the kubelet
+	// reports, such as containerd or docker.
+	Name       string `json:"name"`
+	MinVersion string `json:"minVersion,omitempty"`
+}
+
+// Matrix describes the nodes IOMesh runs on.
+type Matrix struct {
+	Architectures     []string           `json:"architectures"`
+	MinKernelVersion  string             `json:"minKernelVersion"`
+	CgroupVersions    []string           `json:"cgroupVersions"`
+	Distributions     []Distribution     `json:"distributions"`
+	ContainerRuntimes []ContainerRuntime `json:"containerRuntimes"`
+}
+
+// DefaultMatrix is the built-in compatibility matrix.
+var DefaultMatrix = Matrix{
+	Architectures:    []string{"amd64", "arm64"},
+	MinKernelVersion: "3.10",
+	CgroupVersions:   []string{"v1", "v2"},
+	Distributions: []Distribution{
+		{ID: "centos", MinVersion: "7"},
+		{ID: "rhel", MinVersion: "7"},
+		{ID: "rocky", MinVersion: "8"},
+		{ID: "almalinux", MinVersion: "8"},
+		{ID: "ol", MinVersion: "7"},
+		{ID: "openEuler", MinVersion: "20.03"},
+		{ID: "kylin", MinVersion: "V10"},
+		{ID: "uos", MinVersion: "20"},
+		{ID: "ubuntu", MinVersion: "18.04", MinKernelVersion: "4.15"},
+		{ID: "debian", MinVersion: "10", MinKernelVersion: "4.19"},
+	},
+	ContainerRuntimes: []ContainerRuntime{
+		{Name: "docker", MinVersion: "18.09"},
+		{Name: "containerd", MinVersion: "1.3"},
+		{Name: "cri-o", MinVersion: "1.18"},
+	},
+}
+
+// LoadMatrix returns the built-in matrix with the fields set in the YAML file
+// at path replacing its own. An empty path returns the built-in matrix.
+func LoadMatrix(path string) (Matrix, error) {
+	if path == "" {
+		return DefaultMatrix.DeepCopy(), nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Matrix{}, fmt.Errorf("Read compatibility file: %v", err)
+	}
+	// the file is decoded into an empty matrix, decoding over the default
+	// one would reuse the backing arrays of its slices and keep the fields
+	// of its entries the file leaves unset
+	matrix := Matrix{}
+	if err := yaml.UnmarshalStrict(data, &matrix); err != nil {
+		return Matrix{}, fmt.Errorf("Parse compatibility file %v: %v", path, err)
+	}
+	defaults := DefaultMatrix.DeepCopy()
+	if matrix.Architectures == nil {
+		matrix.Architectures = defaults.Architectures
+	}
+	if matrix.MinKernelVersion == "" {
+		matrix.MinKernelVersion = defaults.MinKernelVersion
+	}
+	if matrix.CgroupVersions == nil {
+		matrix.CgroupVersions = defaults.CgroupVersions
+	}
+	if matrix.Distributions == nil {
+		matrix.Distributions = defaults.Distributions
+	}
+	if matrix.ContainerRuntimes == nil {
+		matrix.ContainerRuntimes = defaults.ContainerRuntimes
+	}
+	return matrix, nil
+}
+
+// DeepCopy returns a copy of the matrix sharing no slice with it.
+func (m Matrix) DeepCopy() Matrix {
+	return Matrix{
+		Architectures:     append([]string{}, m.Architectures...),
+		MinKernelVersion:  m.MinKernelVersion,
+		CgroupVersions:    append([]string{}, m.CgroupVersions...),
+		Distributions:     append([]Distribution{}, m.Distributions...),
+		ContainerRuntimes: append([]ContainerRuntime{}, m.ContainerRuntimes...),
+	}
+}
+
+// Distribution returns the entry of a distribution by its os-release ID.
+func (m Matrix) Distribution(id string) (Distribution, bool) {
+	for _, d := range m.Distributions {
+		if strings.EqualFold(d.ID, id) {
+			return d, true
+		}
+	}
+	return Distribution{}, false
+}
+
+// ContainerRuntime returns the entry of a container runtime by its name.
+func (m Matrix) ContainerRuntime(name string) (ContainerRuntime, bool) {
+	for _, r := range m.ContainerRuntimes {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return ContainerRuntime{}, false
+}
+
+// CompareVersions compares the leading dotted numbers of two versions, such as
+// 4.18 in 4.18.0-348.el8.x86_64 or 10 in V10, and returns -1, 0 or 1. Missing
+// components count as 0.
+func CompareVersions(a, b string) int {
+	va, vb := versionNumbers(a), versionNumbers(b)
+	for i := 0; i < len(va) || i < len(vb); i++ {
+		x, y := 0, 0
+		if i < len(va) {
+			x = va[i]
+		}
+		if i < len(vb) {
+			y = vb[i]
+		}
+		if x < y {
+			return -1
+		}
+		if x > y {
+			return 1
+		}
+	}
+	return 0
+}
+
+func versionNumbers(version string) []int {
+	version = strings.TrimLeftFunc(version, func(r rune) bool {
+		return r < '0' || r > '9'
+	})
+	end := strings.IndexFunc(version, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if end >= 0 {
+		version = version[:end]
+	}
+	numbers := []int{}
+	for _, field := range strings.Split(version, ".") {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			break
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers
+}
+
+// atLeast tells whether version is at least min, an empty min always is.
+func atLeast(version, min string) bool {
+	return min == "" || CompareVersions(version, min) >= 0
+}
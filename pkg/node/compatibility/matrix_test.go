@@ -0,0 +1,181 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package compatibility
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadMatrix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compatibility")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defaults := DefaultMatrix.DeepCopy()
+	tests := []struct {
+		name    string
+		content string
+		noFile  bool
+		missing bool
+		wantErr string
+		check   func(t *testing.T, matrix Matrix)
+	}{
+		{
+			name:   "no file",
+			noFile: true,
+			check: func(t *testing.T, matrix Matrix) {
+				if !reflect.DeepEqual(matrix, defaults) {
+					t.Errorf("matrix = %+v, want the default", matrix)
+				}
+			},
+		},
+		{
+			name:    "missing file",
+			missing: true,
+			wantErr: "Read compatibility file",
+		},
+		{
+			name:    "malformed yaml",
+			content: "architectures: [amd64",
+			wantErr: "Parse compatibility file",
+		},
+		{
+			name:    "unknown field",
+			content: "kernelVersion: \"5.4\"\n",
+			wantErr: "Parse compatibility file",
+		},
+		{
+			name:    "empty file",
+			content: "",
+			check: func(t *testing.T, matrix Matrix) {
+				if !reflect.DeepEqual(matrix, defaults) {
+					t.Errorf("matrix = %+v, want the default", matrix)
+				}
+			},
+		},
+		{
+			name:    "architectures only",
+			content: "architectures: [amd64]\n",
+			check: func(t *testing.T, matrix Matrix) {
+				if !reflect.DeepEqual(matrix.Architectures, []string{"amd64"}) {
+					t.Errorf("architectures = %v, want [amd64]", matrix.Architectures)
+				}
+				if !reflect.DeepEqual(matrix.Distributions, defaults.Distributions) {
+					t.Errorf("distributions = %+v, want the default", matrix.Distributions)
+				}
+			},
+		},
+		{
+			// the file lists fewer distributions than the default one,
+			// the entries must not keep the default kernel overrides
+			name: "fewer distributions",
+			content: `
+distributions:
+- id: centos
+  minVersion: "7"
+- id: rhel
+  minVersion: "7"
+- id: rocky
+  minVersion: "8"
+- id: almalinux
+  minVersion: "8"
+- id: ol
+  minVersion: "7"
+- id: openEuler
+  minVersion: "20.03"
+- id: kylin
+  minVersion: V10
+- id: uos
+  minVersion: "20"
+- id: sles
+  minVersion: "12"
+`,
+			check: func(t *testing.T, matrix Matrix) {
+				if len(matrix.Distributions) != 9 {
+					t.Fatalf("got %d distributions, want 9", len(matrix.Distributions))
+				}
+				sles, ok := matrix.Distribution("sles")
+				if !ok || sles.MinKernelVersion != "" {
+					t.Errorf("sles = %+v, %v, want no kernel override", sles, ok)
+				}
+				if _, ok := matrix.Distribution("ubuntu"); ok {
+					t.Errorf("ubuntu still supported")
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := ""
+			switch {
+			case tt.missing:
+				path = filepath.Join(dir, "missing.yaml")
+			case !tt.noFile:
+				path = filepath.Join(dir, strings.Replace(tt.name, " ", "-", -1)+".yaml")
+				if err := ioutil.WriteFile(path, []byte(tt.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			matrix, err := LoadMatrix(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			} else {
+				tt.check(t, matrix)
+				// the loaded matrix shares nothing with the default one
+				if len(matrix.Architectures) > 0 {
+					matrix.Architectures[0] = "changed"
+				}
+			}
+			if !reflect.DeepEqual(DefaultMatrix, defaults) {
+				t.Errorf("LoadMatrix modified DefaultMatrix")
+			}
+		})
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"3.10", "3.10", 0},
+		{"3.10.0-1160.el7.x86_64", "3.10", 0},
+		{"4.18.0-348.el8.x86_64", "3.10", 1},
+		{"3.9", "3.10", -1},
+		{"18.04", "18.04.6", -1},
+		{"V10", "V10", 0},
+		{"V10", "10", 0},
+		{"20.03", "20.3", 0},
+		{"v1.4.12", "1.3", 1},
+		{"garbage", "1", -1},
+	}
+	for i, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("test %d: CompareVersions(%q, %q) = %d, want %d", i, tt.a, tt.b, got, tt.want)
+		}
+	}
+}
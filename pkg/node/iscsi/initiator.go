@@ -0,0 +1,228 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package iscsi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+	"github.com/iomesh/debugtool/pkg/constant"
+	"github.com/iomesh/debugtool/pkg/fixture"
+)
+
+// InitiatorNameFile is where open-iscsi keeps the IQN of the node.
+const InitiatorNameFile = "/etc/iscsi/initiatorname.iscsi"
+
+// readInitiatorCmd reports whether iscsiadm is installed, whether iscsid runs
+// or is socket activated, the IQN of the node and whether iscsi_tcp is loaded,
+// built in or can be loaded. modprobe -n resolves the module in the modules
+// directory of the host without loading it.
+const readInitiatorCmd = `H="nsenter -t 1 -m --"
+$H which iscsiadm >/dev/null 2>&1 && echo installed=yes || echo installed=no
+pgrep -x iscsid >/dev/null && echo iscsid=running || echo iscsid=stopped
+echo iscsid_socket=$($H systemctl is-active iscsid.socket 2>/dev/null)
+if $H test -f ` + InitiatorNameFile + `; then
+  echo iqn=$($H awk -F= '/^InitiatorName=/ {print $2}' ` + InitiatorNameFile + `)
+else
+  echo iqn_file=missing
+fi
+if [ -d /sys/module/iscsi_tcp ]; then echo iscsi_tcp=loaded
+elif $H modprobe -n iscsi_tcp >/dev/null 2>&1; then echo iscsi_tcp=loadable
+else echo iscsi_tcp=missing; fi`
+
+// Initiator is the iSCSI initiator state of a node.
+type Initiator struct {
+	Installed     bool   `json:"installed"`
+	IscsidRunning bool   `json:"iscsidRunning"`
+	IscsidSocket  bool   `json:"iscsidSocket"`
+	IQNFile       bool   `json:"iqnFile"`
+	IQN           string `json:"iqn,omitempty"`
+	// ISCSITCP is loaded, loadable or missing.
+	ISCSITCP string `json:"iscsiTCP"`
+}
+
+// ParseInitiator parses the key=value lines printed by readInitiatorCmd.
+func ParseInitiator(output string) Initiator {
+	initiator := Initiator{IQNFile: true}
+	for _, line := range strings.Split(output, "\n") {
+		kv := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(kv[1])
+		switch kv[0] {
+		case "installed":
+			initiator.Installed = value == "yes"
+		case "iscsid":
+			initiator.IscsidRunning = value == "running"
+		case "iscsid_socket":
+			initiator.IscsidSocket = value == "active"
+		case "iqn_file":
+			initiator.IQNFile = false
+		case "iqn":
+			initiator.IQN = value
+		case "iscsi_tcp":
+			initiator.ISCSITCP = value
+		}
+	}
+	return initiator
+}
+
+// Problems returns what keeps the node from attaching IOMesh volumes.
+func (i Initiator) Problems() []string {
+	problems := []string{}
+	if !i.Installed {
+		problems = append(problems, "open-iscsi is not installed")
+	}
+	if !i.IscsidRunning && !i.IscsidSocket {
+		problems = append(problems, "iscsid is not running")
+	}
+	switch {
+	case !i.IQNFile:
+		problems = append(problems, InitiatorNameFile+" does not exist")
+	case i.IQN == "":
+		problems = append(problems, InitiatorNameFile+" has no InitiatorName")
+	case !strings.HasPrefix(i.IQN, "iqn.") && !strings.HasPrefix(i.IQN, "eui."):
+		problems = append(problems, fmt.Sprintf("initiator name %v is not an IQN", i.IQN))
+	}
+	if i.ISCSITCP == "missing" {
+		problems = append(problems, "kernel module iscsi_tcp is not available")
+	}
+	return problems
+}
+
+func (i Initiator) String() string {
+	facts := []string{}
+	if i.IQN != "" {
+		facts = append(facts, i.IQN)
+	}
+	switch {
+	case i.IscsidRunning:
+		facts = append(facts, "iscsid running")
+	case i.IscsidSocket:
+		facts = append(facts, "iscsid socket activated")
+	}
+	if i.ISCSITCP != "" {
+		facts = append(facts, "iscsi_tcp "+i.ISCSITCP)
+	}
+	return strings.Join(facts, ", ")
+}
+
+type InitiatorChecker struct {
+	checker.Checker
+}
+
+func NewInitiatorChecker() *InitiatorChecker {
+	return &InitiatorChecker{
+		Checker: checker.Newchecker("InitiatorChecker"),
+	}
+}
+
+func init() {
+	checker.Register(NewInitiatorChecker())
+}
+
+func (ic InitiatorChecker) Name() string {
+	return constant.ISCSIInitiatorCheckName
+}
+
+func (ic InitiatorChecker) Category() checker.Category {
+	return checker.CategoryNode
+}
+
+func (ic InitiatorChecker) Dependencies() []string {
+	return nil
+}
+
+func (ic InitiatorChecker) Description() string {
+	return "Checking iSCSI initiator readiness"
+}
+
+func (ic InitiatorChecker) Run(ctx context.Context) checker.Result {
+	result := checker.Result{
+		Status: checker.StatusPass,
+		Remediation: "Install open-iscsi, enable and start iscsid, and give every node a unique InitiatorName in " + InitiatorNameFile +
+			", for example with: echo InitiatorName=$(iscsi-iname) > " + InitiatorNameFile + " && systemctl restart iscsid",
+	}
+
+	pods, err := fixture.GetInstance().NodeCheckerPods(ctx)
+	if err != nil {
+		result.Failf("%v", err)
+		return result
+	}
+
+	initiators := map[string]Initiator{}
+	nodesByIQN := map[string][]string{}
+	unreadable := 0
+	for _, pod := range pods {
+		output, err := ic.RunCmdInPod(pod.Name, constant.DebugNamespace, readInitiatorCmd)
+		if err != nil {
+			unreadable++
+			result.AddSubResult(checker.SubResult{
+				Node:    pod.Spec.NodeName,
+				Status:  checker.StatusFail,
+				Message: fmt.Sprintf("Read iSCSI initiator in pod %s: %v", pod.Name, err),
+			})
+			continue
+		}
+		initiator := ParseInitiator(output)
+		initiators[pod.Spec.NodeName] = initiator
+		if initiator.IQN != "" {
+			nodesByIQN[initiator.IQN] = append(nodesByIQN[initiator.IQN], pod.Spec.NodeName)
+		}
+	}
+
+	nodes := []string{}
+	for node := range initiators {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	duplicated := 0
+	for _, node := range nodes {
+		initiator := initiators[node]
+		problems := initiator.Problems()
+		if others := nodesByIQN[initiator.IQN]; len(others) > 1 {
+			duplicated++
+			problems = append(problems, fmt.Sprintf("IQN shared by nodes %v", strings.Join(others, ", ")))
+		}
+		sr := checker.SubResult{
+			Node:    node,
+			Status:  checker.StatusPass,
+			Message: initiator.String(),
+			Details: initiator,
+		}
+		if len(problems) > 0 {
+			sr.Status = checker.StatusFail
+			sr.Message = strings.Join(problems, "; ")
+		}
+		result.AddSubResult(sr)
+	}
+
+	failed := result.Count(checker.StatusFail)
+	switch {
+	case duplicated > 0:
+		result.Failf("%d nodes share their IQN with another node, %d nodes are not ready", duplicated, failed)
+	case unreadable > 0 && failed == unreadable:
+		result.Failf("Can't read the iSCSI initiator of %d nodes", unreadable)
+	case failed > 0:
+		result.Failf("iSCSI initiator of %d nodes is not ready", failed)
+	default:
+		result.Message = fmt.Sprintf("iSCSI initiator of %d nodes is ready", len(nodes))
+	}
+	return result
+}
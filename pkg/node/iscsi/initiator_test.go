@@ -0,0 +1,132 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package iscsi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseInitiator(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		want     Initiator
+		problems []string
+	}{
+		{
+			name:   "empty output",
+			output: "",
+			want:   Initiator{IQNFile: true},
+			problems: []string{
+				"open-iscsi is not installed",
+				"iscsid is not running",
+				"/etc/iscsi/initiatorname.iscsi has no InitiatorName",
+			},
+		},
+		{
+			name: "ready",
+			output: `installed=yes
+iscsid=running
+iscsid_socket=inactive
+iqn=iqn.1993-08.org.debian:01:8a5d3f2c1b4e
+iscsi_tcp=loaded
+`,
+			want: Initiator{
+				Installed:     true,
+				IscsidRunning: true,
+				IQNFile:       true,
+				IQN:           "iqn.1993-08.org.debian:01:8a5d3f2c1b4e",
+				ISCSITCP:      "loaded",
+			},
+			problems: []string{},
+		},
+		{
+			name: "socket activated iscsid",
+			output: `installed=yes
+iscsid=stopped
+iscsid_socket=active
+iqn=iqn.1994-05.com.redhat:5c2d1e3f4a
+iscsi_tcp=loadable
+`,
+			want: Initiator{
+				Installed:    true,
+				IscsidSocket: true,
+				IQNFile:      true,
+				IQN:          "iqn.1994-05.com.redhat:5c2d1e3f4a",
+				ISCSITCP:     "loadable",
+			},
+			problems: []string{},
+		},
+		{
+			name: "missing iqn file",
+			output: `installed=no
+iscsid=stopped
+iscsid_socket=
+iqn_file=missing
+iscsi_tcp=missing
+`,
+			want: Initiator{ISCSITCP: "missing"},
+			problems: []string{
+				"open-iscsi is not installed",
+				"iscsid is not running",
+				"/etc/iscsi/initiatorname.iscsi does not exist",
+				"kernel module iscsi_tcp is not available",
+			},
+		},
+		{
+			name: "empty initiator name",
+			output: `installed=yes
+iscsid=running
+iqn=
+iscsi_tcp=loaded
+`,
+			want:     Initiator{Installed: true, IscsidRunning: true, IQNFile: true, ISCSITCP: "loaded"},
+			problems: []string{"/etc/iscsi/initiatorname.iscsi has no InitiatorName"},
+		},
+		{
+			name: "not an iqn",
+			output: `installed=yes
+iscsid=running
+iqn=  GenerateName=yes
+iscsi_tcp=loaded
+`,
+			want:     Initiator{Installed: true, IscsidRunning: true, IQNFile: true, IQN: "GenerateName=yes", ISCSITCP: "loaded"},
+			problems: []string{"initiator name GenerateName=yes is not an IQN"},
+		},
+		{
+			name: "eui name",
+			output: `installed=yes
+iscsid=running
+iqn=eui.02004567A425678D
+iscsi_tcp=loaded
+garbage line
+`,
+			want:     Initiator{Installed: true, IscsidRunning: true, IQNFile: true, IQN: "eui.02004567A425678D", ISCSITCP: "loaded"},
+			problems: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseInitiator(tt.output)
+			if got != tt.want {
+				t.Errorf("ParseInitiator() = %+v, want %+v", got, tt.want)
+			}
+			if problems := got.Problems(); !reflect.DeepEqual(problems, tt.problems) {
+				t.Errorf("Problems() = %q, want %q", problems, tt.problems)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tunables
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// meminfoPrefix prefixes the keys of /proc/meminfo fields, such as
+	// meminfo.HugePages_Total.
+	meminfoPrefix = "meminfo."
+	// thpPrefix prefixes the transparent hugepage settings, such as
+	// transparent_hugepage.enabled.
+	thpPrefix = "transparent_hugepage."
+
+	// sysctlFile is where the fix commands persist sysctls.
+	sysctlFile = "/etc/sysctl.d/99-iomesh.conf"
+)
+
+// Expectation is the expected value of a tunable. Key is a sysctl such as
+// vm.swappiness, a /proc/meminfo field prefixed by meminfo. or a transparent
+// hugepage setting prefixed by transparent_hugepage.. Numeric values are
+// bounded by Min and Max, others must be one of OneOf.
+type Expectation struct {
+	Key   string   `json:"key"`
+	Min   *float64 `json:"min,omitempty"`
+	Max   *float64 `json:"max,omitempty"`
+	OneOf []string `json:"oneOf,omitempty"`
+	// Required fails the check on drift, which only warns otherwise.
+	Required bool `json:"required,omitempty"`
+	// Reason tells what the value matters for.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Spec is the expected values of the tunables of every node.
+type Spec struct {
+	Expectations []Expectation `json:"expectations"`
+}
+
+func value(v float64) *float64 {
+	return &v
+}
+
+// DefaultSpec is the built-in spec. It expects the 2MiB hugepages of the
+// small footprint of the node capacity check.
+var DefaultSpec = Spec{
+	Expectations: []Expectation{
+		{Key: "meminfo.Hugepagesize", OneOf: []string{"2048"}, Reason: "IOMesh uses 2MiB hugepages"},
+		{Key: "meminfo.HugePages_Total", Min: value(512), Reason: "hugepages reserved for the chunk service"},
+		{Key: "transparent_hugepage.enabled", OneOf: []string{"madvise", "never"}, Reason: "THP compaction stalls I/O"},
+		{Key: "transparent_hugepage.defrag", OneOf: []string{"madvise", "defer", "defer+madvise", "never"}, Reason: "synchronous THP defragmentation stalls I/O"},
+		{Key: "vm.swappiness", Max: value(10), Reason: "swapping the storage services stalls I/O"},
+		{Key: "vm.dirty_background_ratio", Max: value(10), Reason: "large page cache flushes stall I/O"},
+		{Key: "vm.dirty_ratio", Max: value(20), Reason: "large page cache flushes stall I/O"},
+		{Key: "vm.min_free_kbytes", Min: value(65536), Reason: "atomic network allocations fail under memory pressure"},
+		{Key: "vm.max_map_count", Min: value(262144), Reason: "the storage services map many memory regions"},
+		{Key: "net.core.rmem_max", Min: value(16777216), Reason: "socket buffers limit throughput on fast links"},
+		{Key: "net.core.wmem_max", Min: value(16777216), Reason: "socket buffers limit throughput on fast links"},
+		{Key: "net.core.netdev_max_backlog", Min: value(5000), Reason: "packets are dropped under bursts on fast links"},
+		{Key: "net.core.somaxconn", Min: value(1024), Reason: "connections are dropped under bursts"},
+	},
+}
+
+var keyRegexp = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// LoadSpec returns the built-in spec with the expectations of the YAML file at
+// path replacing those of the same key and appended otherwise. An empty path
+// returns the built-in spec.
+func LoadSpec(path string) (Spec, error) {
+	spec := Spec{Expectations: append([]Expectation{}, DefaultSpec.Expectations...)}
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return spec, fmt.Errorf("Read tunables file: %v", err)
+		}
+		custom := Spec{}
+		if err := yaml.UnmarshalStrict(data, &custom); err != nil {
+			return spec, fmt.Errorf("Parse tunables file %v: %v", path, err)
+		}
+		for _, expectation := range custom.Expectations {
+			replaced := false
+			for i := range spec.Expectations {
+				if spec.Expectations[i].Key == expectation.Key {
+					spec.Expectations[i] = expectation
+					replaced = true
+				}
+			}
+			if !replaced {
+				spec.Expectations = append(spec.Expectations, expectation)
+			}
+		}
+	}
+	for _, expectation := range spec.Expectations {
+		if !keyRegexp.MatchString(expectation.Key) {
+			return spec, fmt.Errorf("Invalid tunable key %q", expectation.Key)
+		}
+	}
+	return spec, nil
+}
+
+// Sysctls returns the sysctl keys of the spec.
+func (s Spec) Sysctls() []string {
+	keys := []string{}
+	for _, expectation := range s.Expectations {
+		if !strings.HasPrefix(expectation.Key, meminfoPrefix) && !strings.HasPrefix(expectation.Key, thpPrefix) {
+			keys = append(keys, expectation.Key)
+		}
+	}
+	return keys
+}
+
+// Check returns why a value does not meet the expectation, empty when it does.
+// A missing value never does.
+func (e Expectation) Check(actual string, ok bool) string {
+	if !ok || actual == "" {
+		return fmt.Sprintf("%s is not available", e.Key)
+	}
+	if len(e.OneOf) > 0 {
+		for _, expected := range e.OneOf {
+			if strings.Join(strings.Fields(expected), " ") == actual {
+				return ""
+			}
+		}
+		return fmt.Sprintf("%s is %s, expected %s", e.Key, actual, strings.Join(e.OneOf, " or "))
+	}
+	if e.Min == nil && e.Max == nil {
+		return ""
+	}
+	number, err := strconv.ParseFloat(actual, 64)
+	if err != nil {
+		return fmt.Sprintf("%s is %s, expected a number", e.Key, actual)
+	}
+	if e.Min != nil && number < *e.Min {
+		return fmt.Sprintf("%s is %s, expected at least %s", e.Key, actual, formatFloat(*e.Min))
+	}
+	if e.Max != nil && number > *e.Max {
+		return fmt.Sprintf("%s is %s, expected at most %s", e.Key, actual, formatFloat(*e.Max))
+	}
+	return ""
+}
+
+// Target is the value the fix command sets.
+func (e Expectation) Target() string {
+	switch {
+	case len(e.OneOf) > 0:
+		return e.OneOf[0]
+	case e.Min != nil:
+		return formatFloat(*e.Min)
+	case e.Max != nil:
+		return formatFloat(*e.Max)
+	}
+	return ""
+}
+
+// FixCommand returns the shell command setting the expected value on a node
+// and persisting it across reboots, empty when it can't be changed at runtime.
+func (e Expectation) FixCommand() string {
+	target := e.Target()
+	if target == "" {
+		return ""
+	}
+	key := e.Key
+	switch {
+	case key == meminfoPrefix+"HugePages_Total":
+		key = "vm.nr_hugepages"
+	case strings.HasPrefix(key, meminfoPrefix):
+		return ""
+	case strings.HasPrefix(key, thpPrefix):
+		return fmt.Sprintf("echo %s > /sys/kernel/mm/transparent_hugepage/%s", target, strings.TrimPrefix(key, thpPrefix))
+	}
+	assignment := key + "=" + target
+	if strings.Contains(target, " ") {
+		assignment = fmt.Sprintf("%s=%q", key, target)
+	}
+	return fmt.Sprintf("sysctl -w %s && echo '%s = %s' >> %s", assignment, key, target, sysctlFile)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tunables
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadSpec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tunables")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defaults := append([]Expectation{}, DefaultSpec.Expectations...)
+	tests := []struct {
+		name    string
+		content string
+		noFile  bool
+		missing bool
+		wantErr string
+		check   func(t *testing.T, spec Spec)
+	}{
+		{
+			name:   "no file",
+			noFile: true,
+			check: func(t *testing.T, spec Spec) {
+				if !reflect.DeepEqual(spec, DefaultSpec) {
+					t.Errorf("spec = %+v, want the default", spec)
+				}
+			},
+		},
+		{
+			name:    "missing file",
+			missing: true,
+			wantErr: "Read tunables file",
+		},
+		{
+			name:    "malformed yaml",
+			content: "expectations: [key: vm.swappiness",
+			wantErr: "Parse tunables file",
+		},
+		{
+			name:    "unknown field",
+			content: "expectations:\n- key: vm.swappiness\n  maximum: 1\n",
+			wantErr: "Parse tunables file",
+		},
+		{
+			name:    "invalid key",
+			content: "expectations:\n- key: vm.x;rm\n  max: 1\n",
+			wantErr: `Invalid tunable key "vm.x;rm"`,
+		},
+		{
+			name:    "empty file",
+			content: "",
+			check: func(t *testing.T, spec Spec) {
+				if !reflect.DeepEqual(spec.Expectations, defaults) {
+					t.Errorf("expectations = %+v, want the default", spec.Expectations)
+				}
+			},
+		},
+		{
+			name: "replace and append",
+			content: `
+expectations:
+- key: vm.swappiness
+  max: 1
+  required: true
+- key: net.ipv6.conf.all.disable_ipv6
+  oneOf: ["0"]
+  reason: IPv6 data network
+`,
+			check: func(t *testing.T, spec Spec) {
+				if len(spec.Expectations) != len(defaults)+1 {
+					t.Fatalf("got %d expectations, want %d", len(spec.Expectations), len(defaults)+1)
+				}
+				for _, e := range spec.Expectations {
+					if e.Key == "vm.swappiness" && (e.Max == nil || *e.Max != 1 || !e.Required) {
+						t.Errorf("vm.swappiness = %+v, want max 1 required", e)
+					}
+				}
+				last := spec.Expectations[len(spec.Expectations)-1]
+				if last.Key != "net.ipv6.conf.all.disable_ipv6" || !reflect.DeepEqual(last.OneOf, []string{"0"}) {
+					t.Errorf("last expectation = %+v", last)
+				}
+			},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := ""
+			switch {
+			case tt.missing:
+				path = filepath.Join(dir, "missing.yaml")
+			case !tt.noFile:
+				path = filepath.Join(dir, strings.Replace(tt.name, " ", "-", -1)+".yaml")
+				if err := ioutil.WriteFile(path, []byte(tt.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			spec, err := LoadSpec(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("test %d: err = %v, want %q", i, err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("test %d: unexpected error %v", i, err)
+			} else {
+				tt.check(t, spec)
+			}
+			if !reflect.DeepEqual(DefaultSpec.Expectations, defaults) {
+				t.Errorf("test %d: LoadSpec modified DefaultSpec", i)
+			}
+		})
+	}
+}
+
+func TestExpectationCheck(t *testing.T) {
+	tests := []struct {
+		expectation Expectation
+		actual      string
+		ok          bool
+		want        string
+	}{
+		{Expectation{Key: "vm.swappiness", Max: value(10)}, "", false, "vm.swappiness is not available"},
+		{Expectation{Key: "vm.swappiness", Max: value(10)}, "", true, "vm.swappiness is not available"},
+		{Expectation{Key: "vm.swappiness", Max: value(10)}, "10", true, ""},
+		{Expectation{Key: "vm.swappiness", Max: value(10)}, "60", true, "vm.swappiness is 60, expected at most 10"},
+		{Expectation{Key: "vm.swappiness", Max: value(10)}, "sixty", true, "vm.swappiness is sixty, expected a number"},
+		{Expectation{Key: "vm.max_map_count", Min: value(262144)}, "65530", true, "vm.max_map_count is 65530, expected at least 262144"},
+		{Expectation{Key: "transparent_hugepage.enabled", OneOf: []string{"madvise", "never"}}, "never", true, ""},
+		{Expectation{Key: "transparent_hugepage.enabled", OneOf: []string{"madvise", "never"}}, "always", true, "transparent_hugepage.enabled is always, expected madvise or never"},
+		{Expectation{Key: "net.ipv4.tcp_rmem", OneOf: []string{"4096  87380 16777216"}}, "4096 87380 16777216", true, ""},
+		{Expectation{Key: "kernel.hostname"}, "node1", true, ""},
+	}
+	for i, tt := range tests {
+		if got := tt.expectation.Check(tt.actual, tt.ok); got != tt.want {
+			t.Errorf("test %d: Check(%q, %v) = %q, want %q", i, tt.actual, tt.ok, got, tt.want)
+		}
+	}
+}
+
+func TestExpectationFixCommand(t *testing.T) {
+	tests := []struct {
+		expectation Expectation
+		want        string
+	}{
+		{Expectation{Key: "vm.swappiness", Max: value(10)}, "sysctl -w vm.swappiness=10 && echo 'vm.swappiness = 10' >> /etc/sysctl.d/99-iomesh.conf"},
+		{Expectation{Key: "meminfo.HugePages_Total", Min: value(512)}, "sysctl -w vm.nr_hugepages=512 && echo 'vm.nr_hugepages = 512' >> /etc/sysctl.d/99-iomesh.conf"},
+		{Expectation{Key: "meminfo.Hugepagesize", OneOf: []string{"2048"}}, ""},
+		{Expectation{Key: "transparent_hugepage.defrag", OneOf: []string{"madvise"}}, "echo madvise > /sys/kernel/mm/transparent_hugepage/defrag"},
+		{Expectation{Key: "net.ipv4.tcp_rmem", OneOf: []string{"4096 87380 16777216"}}, `sysctl -w net.ipv4.tcp_rmem="4096 87380 16777216" && echo 'net.ipv4.tcp_rmem = 4096 87380 16777216' >> /etc/sysctl.d/99-iomesh.conf`},
+		{Expectation{Key: "kernel.hostname"}, ""},
+	}
+	for i, tt := range tests {
+		if got := tt.expectation.FixCommand(); got != tt.want {
+			t.Errorf("test %d: FixCommand() = %q, want %q", i, got, tt.want)
+		}
+	}
+}
+
+func TestParseTunables(t *testing.T) {
+	tests := []struct {
+		output string
+		want   map[string]string
+	}{
+		{"", map[string]string{}},
+		{"garbage\nno separator here\n", map[string]string{}},
+		{
+			"vm.swappiness=60\nnet.ipv4.tcp_rmem=4096\t87380   6291456\n  meminfo.HugePages_Total=0  \ntransparent_hugepage.enabled=always\n",
+			map[string]string{
+				"vm.swappiness":                "60",
+				"net.ipv4.tcp_rmem":            "4096 87380 6291456",
+				"meminfo.HugePages_Total":      "0",
+				"transparent_hugepage.enabled": "always",
+			},
+		},
+		{"vm.swappiness=\n", map[string]string{"vm.swappiness": ""}},
+	}
+	for i, tt := range tests {
+		if got := ParseTunables(tt.output); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test %d: ParseTunables() = %v, want %v", i, got, tt.want)
+		}
+	}
+}
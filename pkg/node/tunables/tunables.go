@@ -0,0 +1,171 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tunables
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+	"github.com/iomesh/debugtool/pkg/config"
+	"github.com/iomesh/debugtool/pkg/constant"
+	"github.com/iomesh/debugtool/pkg/fixture"
+	"github.com/iomesh/debugtool/pkg/kutils"
+)
+
+// readTunablesCmd prints the hugepage fields of /proc/meminfo, the selected
+// transparent hugepage modes and the given sysctls as key=value lines. The
+// hostnetwork checker pods share the network namespace of the host, so
+// net.* sysctls are those of the host.
+func readTunablesCmd(sysctls []string) string {
+	return `awk -F: '/^(HugePages_|Hugepagesize)/ {sub(/kB/, "", $2); gsub(/[ \t]/, "", $2); print "meminfo." $1 "=" $2}' /proc/meminfo
+for f in enabled defrag; do
+  echo "transparent_hugepage.$f=$(sed -n 's/.*\[\(.*\)\].*/\1/p' /sys/kernel/mm/transparent_hugepage/$f 2>/dev/null)"
+done
+for k in ` + strings.Join(sysctls, " ") + `; do
+  echo "$k=$(cat /proc/sys/$(echo $k | tr . /) 2>/dev/null)"
+done
+true`
+}
+
+// ParseTunables parses the key=value lines printed by readTunablesCmd, with
+// the whitespace inside values normalized to single spaces.
+func ParseTunables(output string) map[string]string {
+	values := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		kv := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		values[kv[0]] = strings.Join(strings.Fields(kv[1]), " ")
+	}
+	return values
+}
+
+type TunablesChecker struct {
+	checker.Checker
+}
+
+func NewTunablesChecker() *TunablesChecker {
+	return &TunablesChecker{
+		Checker: checker.Newchecker("TunablesChecker"),
+	}
+}
+
+func init() {
+	checker.Register(NewTunablesChecker())
+}
+
+func (tc TunablesChecker) Name() string {
+	return constant.KernelTunablesCheckName
+}
+
+func (tc TunablesChecker) Category() checker.Category {
+	return checker.CategoryNode
+}
+
+func (tc TunablesChecker) Dependencies() []string {
+	return nil
+}
+
+func (tc TunablesChecker) Description() string {
+	return "Checking hugepages and kernel tunables"
+}
+
+func (tc TunablesChecker) Run(ctx context.Context) checker.Result {
+	result := checker.Result{
+		Status:      checker.StatusPass,
+		Remediation: "Set the flagged hugepages and sysctls to their expected values on every node",
+	}
+	spec, err := LoadSpec(config.Get().TunablesFile)
+	if err != nil {
+		result.Failf("%v", err)
+		return result
+	}
+
+	if err := fixture.GetInstance().EnsureHostNetworkDsDeployed(ctx); err != nil {
+		result.Failf("%v", err)
+		return result
+	}
+	pods, err := kutils.ListPods(ctx, tc.Client, constant.DebugNamespace, constant.HostNetworkCheckerLabel)
+	if err != nil {
+		result.Failf("List hostnetwork checker pods: %v", err)
+		return result
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].Spec.NodeName < pods[j].Spec.NodeName
+	})
+
+	cmd := readTunablesCmd(spec.Sysctls())
+	fixes := []string{}
+	seenFixes := map[string]bool{}
+	for _, pod := range pods {
+		sr := checker.SubResult{
+			Node:   pod.Spec.NodeName,
+			Status: checker.StatusPass,
+		}
+		output, err := tc.RunCmdInPod(pod.Name, constant.DebugNamespace, cmd)
+		if err != nil {
+			sr.Status = checker.StatusFail
+			sr.Message = fmt.Sprintf("Read tunables in pod %s: %v", pod.Name, err)
+			result.AddSubResult(sr)
+			continue
+		}
+		values := ParseTunables(output)
+		sr.Details = values
+
+		drifts := []string{}
+		for _, expectation := range spec.Expectations {
+			actual, ok := values[expectation.Key]
+			drift := expectation.Check(actual, ok)
+			if drift == "" {
+				continue
+			}
+			if expectation.Reason != "" {
+				drift += ", " + expectation.Reason
+			}
+			drifts = append(drifts, drift)
+			if expectation.Required {
+				sr.Status = checker.StatusFail
+			} else {
+				sr.Status = checker.Worse(sr.Status, checker.StatusWarn)
+			}
+			if fix := expectation.FixCommand(); fix != "" && !seenFixes[fix] {
+				seenFixes[fix] = true
+				fixes = append(fixes, fix)
+			}
+		}
+		if len(drifts) > 0 {
+			sr.Message = strings.Join(drifts, "; ")
+		} else {
+			sr.Message = fmt.Sprintf("%d tunables as expected", len(spec.Expectations))
+		}
+		result.AddSubResult(sr)
+	}
+
+	if len(fixes) > 0 {
+		result.Remediation = "Run on the flagged nodes: " + strings.Join(fixes, "; ")
+	}
+	if failed := result.Count(checker.StatusFail); failed > 0 {
+		result.Failf("Required tunables differ from the expected values on %d nodes", failed)
+	} else if drifted := result.Count(checker.StatusWarn); drifted > 0 {
+		result.Warnf("Tunables differ from the expected values on %d nodes", drifted)
+	} else {
+		result.Message = fmt.Sprintf("Hugepages and kernel tunables as expected on %d nodes", len(pods))
+	}
+	return result
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package report
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+	"github.com/iomesh/debugtool/pkg/config"
+)
+
+// ClusterInfo describes the cluster the checks were run against.
+type ClusterInfo struct {
+	KubernetesVersion string     `json:"kubernetesVersion"`
+	DataCIDR          string     `json:"dataCIDR,omitempty"`
+	Nodes             []NodeInfo `json:"nodes"`
+}
+
+type NodeInfo struct {
+	Name             string `json:"name"`
+	InternalIP       string `json:"internalIP,omitempty"`
+	KubeletVersion   string `json:"kubeletVersion"`
+	OSImage          string `json:"osImage"`
+	KernelVersion    string `json:"kernelVersion"`
+	Architecture     string `json:"architecture"`
+	ContainerRuntime string `json:"containerRuntime"`
+}
+
+// GetClusterInfo collects the cluster metadata included in the report.
+func GetClusterInfo(ctx context.Context, c checker.Checker) (ClusterInfo, error) {
+	info := ClusterInfo{
+		DataCIDR: config.Get().DataCIDR,
+		Nodes:    []NodeInfo{},
+	}
+
+	version, err := c.ClientSet.Discovery().ServerVersion()
+	if err != nil {
+		return info, fmt.Errorf("Get kubernetes version: %v", err)
+	}
+	info.KubernetesVersion = version.GitVersion
+
+	nodeList := &corev1.NodeList{}
+	if err := c.Client.List(ctx, nodeList, &client.ListOptions{}); err != nil {
+		return info, fmt.Errorf("List nodes: %v", err)
+	}
+	for _, node := range nodeList.Items {
+		nodeInfo := NodeInfo{
+			Name:             node.Name,
+			KubeletVersion:   node.Status.NodeInfo.KubeletVersion,
+			OSImage:          node.Status.NodeInfo.OSImage,
+			KernelVersion:    node.Status.NodeInfo.KernelVersion,
+			Architecture:     node.Status.NodeInfo.Architecture,
+			ContainerRuntime: node.Status.NodeInfo.ContainerRuntimeVersion,
+		}
+		for _, addr := range node.Status.Addresses {
+			if addr.Type == corev1.NodeInternalIP {
+				nodeInfo.InternalIP = addr.Address
+				break
+			}
+		}
+		info.Nodes = append(info.Nodes, nodeInfo)
+	}
+	return info, nil
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+)
+
+// WriteHTML renders the report as a single self-contained HTML page, with
+// inline styles and no external assets, so it can be attached to a ticket.
+func WriteHTML(w io.Writer, r Report) error {
+	if err := htmlTemplate.Execute(w, r); err != nil {
+		return fmt.Errorf("Render html report: %v", err)
+	}
+	return nil
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"emoji":    StatusEmoji,
+	"title":    CategoryTitle,
+	"matrices": Matrices,
+	"measurements": func(measurements []checker.Measurement) string {
+		return formatMeasurements(measurements)
+	},
+	"duration": func(d time.Duration) string {
+		return d.Round(time.Millisecond).String()
+	},
+	"time": func(t time.Time) string {
+		return t.Format(time.RFC1123)
+	},
+	"cellColor": cellColor,
+	"cellValue": func(cell MatrixCell) string {
+		if cell.Measured {
+			return fmt.Sprintf("%.2f", cell.Value)
+		}
+		if cell.Status != "" {
+			return string(StatusEmoji(cell.Status))
+		}
+		return "-"
+	},
+}).Parse(htmlSource))
+
+// cellColor maps a matrix cell onto a red to green scale relative to the
+// other values of the matrix.
+func cellColor(matrix Matrix, cell MatrixCell) template.CSS {
+	switch {
+	case cell.Status == checker.StatusFail:
+		return "#e57373"
+	case !cell.Measured && cell.Status == "":
+		return "#eeeeee"
+	case !cell.Measured:
+		return "#81c784"
+	}
+	ratio := 1.0
+	if matrix.Max > matrix.Min {
+		ratio = (cell.Value - matrix.Min) / (matrix.Max - matrix.Min)
+	}
+	if !matrix.HigherIsBetter {
+		ratio = 1 - ratio
+	}
+	return template.CSS(fmt.Sprintf("hsl(%d, 65%%, 65%%)", int(120*ratio)))
+}
+
+const htmlSource = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>IOMesh debugtool report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+h1 { margin-bottom: 0; }
+h2 { border-bottom: 1px solid #ccc; padding-bottom: .2em; margin-top: 2em; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #ccc; padding: .3em .6em; text-align: left; vertical-align: top; }
+th { background: #f5f5f5; }
+.muted { color: #777; }
+.pass { background: #e8f5e9; }
+.warn { background: #fff8e1; }
+.fail { background: #ffebee; }
+.skip { background: #eceff1; }
+.heatmap td { text-align: center; min-width: 5em; }
+.remediation { font-style: italic; }
+</style>
+</head>
+<body>
+<h1>IOMesh debugtool report {{ emoji .Status }}</h1>
+<p class="muted">Generated {{ time .StartTime }} in {{ duration .Duration }} &middot; schema {{ .APIVersion }}</p>
+
+<h2>Cluster</h2>
+<table>
+<tr><th>Kubernetes version</th><td>{{ .Cluster.KubernetesVersion }}</td></tr>
+<tr><th>Data CIDR</th><td>{{ .Cluster.DataCIDR }}</td></tr>
+<tr><th>Nodes</th><td>{{ len .Cluster.Nodes }}</td></tr>
+</table>
+<table>
+<tr><th>Node</th><th>Internal IP</th><th>OS</th><th>Kernel</th><th>Arch</th><th>Container runtime</th><th>Kubelet</th></tr>
+{{- range .Cluster.Nodes }}
+<tr><td>{{ .Name }}</td><td>{{ .InternalIP }}</td><td>{{ .OSImage }}</td><td>{{ .KernelVersion }}</td><td>{{ .Architecture }}</td><td>{{ .ContainerRuntime }}</td><td>{{ .KubeletVersion }}</td></tr>
+{{- end }}
+</table>
+
+<h2>Summary</h2>
+{{- with .Summary }}
+<p>{{ .Total }} checks: {{ .Pass }} passed, {{ .Warn }} warned, {{ .Fail }} failed, {{ .Skip }} skipped</p>
+{{- end }}
+<table>
+<tr><th></th><th>Category</th><th>Check</th><th>Result</th><th>Duration</th><th>Remediation</th></tr>
+{{- range .Results }}
+<tr class="{{ .Status }}"><td>{{ emoji .Status }}</td><td>{{ title .Category }}</td><td><a href="#{{ .Name }}">{{ .Name }}</a></td><td>{{ .Message }} {{ measurements .Measurements }}</td><td>{{ duration .Duration }}</td><td class="remediation">{{ if ne .Status "pass" }}{{ .Remediation }}{{ end }}</td></tr>
+{{- end }}
+</table>
+
+{{- range .Results }}
+<h2 id="{{ .Name }}">{{ emoji .Status }} {{ .Name }}</h2>
+{{- if .Message }}
+<p>{{ .Message }}</p>
+{{- end }}
+{{- if and .Remediation (ne .Status "pass") }}
+<p class="remediation">Remediation: {{ .Remediation }}</p>
+{{- end }}
+{{- range matrices . }}
+{{- $matrix := . }}
+<h3>{{ if .Family }}{{ .Family }} {{ end }}{{ if .Measurement }}{{ .Measurement }}{{ if .Unit }} ({{ .Unit }}){{ end }}{{ else }}status{{ end }}</h3>
+<table class="heatmap">
+<tr><th>source \ destination</th>{{ range .Nodes }}<th>{{ . }}</th>{{ end }}</tr>
+{{- range $i, $row := .Cells }}
+<tr><th>{{ index $matrix.Nodes $i }}</th>{{ range $row }}<td style="background: {{ cellColor $matrix . }}">{{ cellValue . }}</td>{{ end }}</tr>
+{{- end }}
+</table>
+{{- end }}
+{{- if .SubResults }}
+<table>
+<tr><th></th><th>Subject</th><th>Peer</th><th>Details</th></tr>
+{{- range .SubResults }}
+<tr class="{{ .Status }}"><td>{{ emoji .Status }}</td><td>{{ if .Object }}{{ .Object }}{{ if .Node }}@{{ .Node }}{{ end }}{{ else }}{{ .Node }}{{ if .Device }}:{{ .Device }}{{ end }}{{ end }}</td><td>{{ .Peer }}{{ if .Family }} ({{ .Family }}){{ end }}</td><td>{{ .Message }} {{ measurements .Measurements }}</td></tr>
+{{- end }}
+</table>
+{{- end }}
+{{- end }}
+</body>
+</html>
+`
@@ -0,0 +1,63 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+)
+
+func TestWriteHTML(t *testing.T) {
+	r := New()
+	r.Duration = 3 * time.Second
+	r.Cluster = ClusterInfo{KubernetesVersion: "v1.20.2", DataCIDR: "10.0.0.0/24,fd00:10::/64"}
+	r.Results = append(r.Results, dualStackResult(), checker.Result{
+		Name:        "dns",
+		Category:    checker.CategoryInfra,
+		Status:      checker.StatusWarn,
+		Message:     "Slow <lookups>",
+		Remediation: "Scale coredns",
+		SubResults: []checker.SubResult{
+			{Object: "deployment/coredns", Status: checker.StatusPass},
+			{Object: "pod/coredns-1", Node: "node-a", Status: checker.StatusWarn},
+			{Node: "node-b", Device: "sdb", Status: checker.StatusPass},
+		},
+	})
+
+	buf := &bytes.Buffer{}
+	if err := Write(buf, FormatHTML, r); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	html := buf.String()
+	for _, want := range []string{
+		"<h3>IPv4 latency (ms)</h3>",
+		"<h3>IPv6 latency (ms)</h3>",
+		"<td>deployment/coredns</td>",
+		"<td>pod/coredns-1@node-a</td>",
+		"<td>node-b:sdb</td>",
+		"<td>node-a (IPv6)</td>",
+		"Slow &lt;lookups&gt;",
+		"Remediation: Scale coredns",
+		"</html>",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("WriteHTML() output is missing %q", want)
+		}
+	}
+}
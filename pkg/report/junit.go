@@ -0,0 +1,181 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	TestCases  []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit renders the report as JUnit XML. Every category becomes a test
+// suite, and every check as well as every node or node pair of a check
+// becomes a test case.
+func WriteJUnit(w io.Writer, r Report) error {
+	suites := junitTestSuites{
+		Name: "debugtool",
+		Time: junitSeconds(r.Duration),
+	}
+
+	suiteIdx := map[checker.Category]int{}
+	suiteDurations := map[checker.Category]time.Duration{}
+	for _, result := range r.Results {
+		idx, ok := suiteIdx[result.Category]
+		if !ok {
+			idx = len(suites.Suites)
+			suiteIdx[result.Category] = idx
+			suites.Suites = append(suites.Suites, junitTestSuite{
+				Name:       string(result.Category),
+				Timestamp:  r.StartTime.Format(time.RFC3339),
+				Properties: junitProperties(r.Cluster),
+			})
+		}
+		suite := &suites.Suites[idx]
+
+		testCases := []junitTestCase{junitCheckTestCase(result)}
+		for _, sr := range result.SubResults {
+			testCases = append(testCases, junitSubResultTestCase(result, sr))
+		}
+		for _, tc := range testCases {
+			suite.Tests++
+			if tc.Failure != nil {
+				suite.Failures++
+			}
+			if tc.Skipped != nil {
+				suite.Skipped++
+			}
+		}
+		suite.TestCases = append(suite.TestCases, testCases...)
+		suiteDurations[result.Category] += result.Duration
+	}
+
+	for i := range suites.Suites {
+		suite := &suites.Suites[i]
+		suite.Time = junitSeconds(suiteDurations[checker.Category(suite.Name)])
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Skipped += suite.Skipped
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return fmt.Errorf("Marshal junit report: %v", err)
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+func junitCheckTestCase(result checker.Result) junitTestCase {
+	tc := junitTestCase{
+		ClassName: string(result.Category),
+		Name:      result.Name,
+		Time:      junitSeconds(result.Duration),
+		SystemOut: formatMeasurements(result.Measurements),
+	}
+	details := result.Message
+	if result.Remediation != "" {
+		details = strings.TrimSpace(details + "\nRemediation: " + result.Remediation)
+	}
+	setJUnitStatus(&tc, result.Status, result.Message, details)
+	return tc
+}
+
+func junitSubResultTestCase(result checker.Result, sr checker.SubResult) junitTestCase {
+	tc := junitTestCase{
+		ClassName: fmt.Sprintf("%s.%s", result.Category, result.Name),
+		Name:      sr.Name(),
+		Time:      junitSeconds(0),
+		SystemOut: strings.TrimSpace(sr.Message + " " + formatMeasurements(sr.Measurements)),
+	}
+	setJUnitStatus(&tc, sr.Status, sr.Message, sr.Message)
+	return tc
+}
+
+// setJUnitStatus maps a status onto JUnit. Warnings are not failures, their
+// message is kept in the test case output.
+func setJUnitStatus(tc *junitTestCase, status checker.Status, message, details string) {
+	switch status {
+	case checker.StatusFail:
+		tc.Failure = &junitMessage{Message: message, Type: string(status), Text: details}
+	case checker.StatusSkip:
+		tc.Skipped = &junitMessage{Message: message}
+	case checker.StatusWarn:
+		tc.SystemOut = strings.TrimSpace(fmt.Sprintf("warning: %s\n%s", message, tc.SystemOut))
+	}
+}
+
+func junitProperties(cluster ClusterInfo) []junitProperty {
+	properties := []junitProperty{
+		{Name: "kubernetesVersion", Value: cluster.KubernetesVersion},
+		{Name: "nodes", Value: fmt.Sprintf("%d", len(cluster.Nodes))},
+	}
+	if cluster.DataCIDR != "" {
+		properties = append(properties, junitProperty{Name: "dataCIDR", Value: cluster.DataCIDR})
+	}
+	return properties
+}
+
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
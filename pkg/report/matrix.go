@@ -0,0 +1,141 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package report
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+)
+
+// Matrix is a node to node view of the pair sub-results of a check. Rows are
+// the source nodes and columns the destination nodes.
+type Matrix struct {
+	Check string
+	// Family is the address family of the sub-results of the matrix, empty
+	// unless the check measured each family separately.
+	Family      string
+	Measurement string
+	Unit        string
+	// HigherIsBetter tells whether large values are good, like bandwidth,
+	// or bad, like latency.
+	HigherIsBetter bool
+	Nodes          []string
+	Cells          [][]MatrixCell
+	Min, Max       float64
+}
+
+type MatrixCell struct {
+	Measured bool
+	Value    float64
+	Status   checker.Status
+}
+
+// Matrices builds one matrix per address family and measurement found in the
+// pair sub-results of the check. A check with pair sub-results but no
+// measurements, like a connectivity check, gets a single matrix of statuses
+// per family.
+func Matrices(result checker.Result) []Matrix {
+	nodeSet := map[string]bool{}
+	families := []string{}
+	familySet := map[string]bool{}
+	measurements := []string{}
+	units := map[string]string{}
+	for _, sr := range result.SubResults {
+		if sr.Peer == "" {
+			continue
+		}
+		if !familySet[sr.Family] {
+			familySet[sr.Family] = true
+			families = append(families, sr.Family)
+		}
+		nodeSet[sr.Node] = true
+		nodeSet[sr.Peer] = true
+		for _, m := range sr.Measurements {
+			if _, ok := units[m.Name]; !ok {
+				measurements = append(measurements, m.Name)
+				units[m.Name] = m.Unit
+			}
+		}
+	}
+	if len(nodeSet) == 0 {
+		return nil
+	}
+	if len(measurements) == 0 {
+		measurements = append(measurements, "")
+	}
+
+	nodes := []string{}
+	for node := range nodeSet {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	nodeIdx := map[string]int{}
+	for i, node := range nodes {
+		nodeIdx[node] = i
+	}
+
+	matrices := []Matrix{}
+	for _, family := range families {
+		for _, name := range measurements {
+			matrices = append(matrices, buildMatrix(result, family, name, units[name], nodes, nodeIdx))
+		}
+	}
+	return matrices
+}
+
+func buildMatrix(result checker.Result, family, name, unit string, nodes []string, nodeIdx map[string]int) Matrix {
+	matrix := Matrix{
+		Check:          result.Name,
+		Family:         family,
+		Measurement:    name,
+		Unit:           unit,
+		HigherIsBetter: higherIsBetter(name),
+		Nodes:          nodes,
+		Cells:          make([][]MatrixCell, len(nodes)),
+	}
+	for i := range matrix.Cells {
+		matrix.Cells[i] = make([]MatrixCell, len(nodes))
+	}
+
+	first := true
+	for _, sr := range result.SubResults {
+		if sr.Peer == "" || sr.Family != family {
+			continue
+		}
+		cell := &matrix.Cells[nodeIdx[sr.Node]][nodeIdx[sr.Peer]]
+		cell.Status = sr.Status
+		for _, m := range sr.Measurements {
+			if m.Name != name {
+				continue
+			}
+			cell.Measured = true
+			cell.Value = m.Value
+			if first || m.Value < matrix.Min {
+				matrix.Min = m.Value
+			}
+			if first || m.Value > matrix.Max {
+				matrix.Max = m.Value
+			}
+			first = false
+		}
+	}
+	return matrix
+}
+
+func higherIsBetter(measurement string) bool {
+	return strings.Contains(measurement, "bandwidth") || strings.Contains(measurement, "throughput")
+}
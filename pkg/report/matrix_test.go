@@ -0,0 +1,144 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package report
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+)
+
+// dualStackResult is a latency check measured over both families, the IPv6
+// probe from node-b failed before measuring anything.
+func dualStackResult() checker.Result {
+	latency := func(ms float64) []checker.Measurement {
+		return []checker.Measurement{{Name: "latency", Value: ms, Unit: "ms"}}
+	}
+	return checker.Result{
+		Name:     "network-latency",
+		Category: checker.CategoryNetwork,
+		Status:   checker.StatusFail,
+		SubResults: []checker.SubResult{
+			{Node: "node-b", Status: checker.StatusPass, Message: "node only, not in a matrix"},
+			{Node: "node-a", Peer: "node-b", Family: "IPv4", Status: checker.StatusPass, Measurements: latency(0.2)},
+			{Node: "node-a", Peer: "node-b", Family: "IPv6", Status: checker.StatusPass, Measurements: latency(0.3)},
+			{Node: "node-b", Peer: "node-a", Family: "IPv4", Status: checker.StatusWarn, Measurements: latency(0.8)},
+			{Node: "node-b", Peer: "node-a", Family: "IPv6", Status: checker.StatusFail, Message: "ping6 failed"},
+		},
+	}
+}
+
+func TestMatrices(t *testing.T) {
+	nodes := []string{"node-a", "node-b"}
+	tests := []struct {
+		name   string
+		result checker.Result
+		want   []Matrix
+	}{
+		{
+			name: "no pair sub-results",
+			result: checker.Result{
+				Name:       "clock-skew",
+				SubResults: []checker.SubResult{{Node: "node-a", Status: checker.StatusPass}},
+			},
+			want: nil,
+		},
+		{
+			name:   "dual-stack measurements",
+			result: dualStackResult(),
+			want: []Matrix{
+				{
+					Check:       "network-latency",
+					Family:      "IPv4",
+					Measurement: "latency",
+					Unit:        "ms",
+					Nodes:       nodes,
+					Cells: [][]MatrixCell{
+						{{}, {Measured: true, Value: 0.2, Status: checker.StatusPass}},
+						{{Measured: true, Value: 0.8, Status: checker.StatusWarn}, {}},
+					},
+					Min: 0.2,
+					Max: 0.8,
+				},
+				{
+					Check:       "network-latency",
+					Family:      "IPv6",
+					Measurement: "latency",
+					Unit:        "ms",
+					Nodes:       nodes,
+					Cells: [][]MatrixCell{
+						{{}, {Measured: true, Value: 0.3, Status: checker.StatusPass}},
+						{{Status: checker.StatusFail}, {}},
+					},
+					Min: 0.3,
+					Max: 0.3,
+				},
+			},
+		},
+		{
+			name: "statuses only",
+			result: checker.Result{
+				Name: "network-connectivity",
+				SubResults: []checker.SubResult{
+					{Node: "node-a", Peer: "node-b", Status: checker.StatusPass},
+					{Node: "node-b", Peer: "node-a", Status: checker.StatusFail},
+				},
+			},
+			want: []Matrix{
+				{
+					Check: "network-connectivity",
+					Nodes: nodes,
+					Cells: [][]MatrixCell{
+						{{}, {Status: checker.StatusPass}},
+						{{Status: checker.StatusFail}, {}},
+					},
+				},
+			},
+		},
+		{
+			name: "bandwidth",
+			result: checker.Result{
+				Name: "hostnetwork-bandwidth",
+				SubResults: []checker.SubResult{
+					{Node: "node-a", Peer: "node-b", Status: checker.StatusPass,
+						Measurements: []checker.Measurement{{Name: "bandwidth", Value: 9.4, Unit: "Gbit/s"}}},
+				},
+			},
+			want: []Matrix{
+				{
+					Check:          "hostnetwork-bandwidth",
+					Measurement:    "bandwidth",
+					Unit:           "Gbit/s",
+					HigherIsBetter: true,
+					Nodes:          nodes,
+					Cells: [][]MatrixCell{
+						{{}, {Measured: true, Value: 9.4, Status: checker.StatusPass}},
+						{{}, {}},
+					},
+					Min: 9.4,
+					Max: 9.4,
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Matrices(tt.result); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Matrices() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	FormatText  = "text"
+	FormatJSON  = "json"
+	FormatYAML  = "yaml"
+	FormatJUnit = "junit"
+	FormatHTML  = "html"
+)
+
+// Formats lists every supported output format.
+var Formats = []string{FormatText, FormatJSON, FormatYAML, FormatJUnit, FormatHTML}
+
+// Write renders the report in the given format.
+func Write(w io.Writer, format string, r Report) error {
+	switch format {
+	case FormatText:
+		return WriteText(w, r)
+	case FormatJSON:
+		return WriteJSON(w, r)
+	case FormatYAML:
+		return WriteYAML(w, r)
+	case FormatJUnit:
+		return WriteJUnit(w, r)
+	case FormatHTML:
+		return WriteHTML(w, r)
+	default:
+		return fmt.Errorf("Unknown output format %s", format)
+	}
+}
+
+func WriteJSON(w io.Writer, r Report) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Marshal report: %v", err)
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+func WriteYAML(w io.Writer, r Report) error {
+	data, err := yaml.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("Marshal report: %v", err)
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// WriteFile renders the report in the given format to a file.
+func WriteFile(path, format string, r Report) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("Create report file %s: %v", path, err)
+	}
+	if err := Write(file, format, r); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/enescakir/emoji"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+)
+
+const (
+	// APIVersion versions the schema of the machine readable reports. It
+	// must be bumped whenever a field is renamed or removed.
+	APIVersion = "debugtool.iomesh.com/v1alpha1"
+	Kind       = "Report"
+)
+
+// Report collects the results of every check run by a command.
+type Report struct {
+	APIVersion string           `json:"apiVersion"`
+	Kind       string           `json:"kind"`
+	StartTime  time.Time        `json:"startTime"`
+	Duration   time.Duration    `json:"-"`
+	Cluster    ClusterInfo      `json:"cluster"`
+	Results    []checker.Result `json:"checks"`
+}
+
+// Summary counts the results by status.
+type Summary struct {
+	Total int `json:"total"`
+	Pass  int `json:"pass"`
+	Warn  int `json:"warn"`
+	Fail  int `json:"fail"`
+	Skip  int `json:"skip"`
+}
+
+func New() Report {
+	return Report{
+		APIVersion: APIVersion,
+		Kind:       Kind,
+		StartTime:  time.Now(),
+		Results:    []checker.Result{},
+	}
+}
+
+func (r Report) MarshalJSON() ([]byte, error) {
+	type report Report
+	return json.Marshal(struct {
+		report
+		DurationSeconds float64        `json:"durationSeconds"`
+		Status          checker.Status `json:"status"`
+		Summary         Summary        `json:"summary"`
+	}{
+		report:          report(r),
+		DurationSeconds: r.Duration.Seconds(),
+		Status:          r.Status(),
+		Summary:         r.Summary(),
+	})
+}
+
+func (r Report) Summary() Summary {
+	return Summary{
+		Total: len(r.Results),
+		Pass:  r.Count(checker.StatusPass),
+		Warn:  r.Count(checker.StatusWarn),
+		Fail:  r.Count(checker.StatusFail),
+		Skip:  r.Count(checker.StatusSkip),
+	}
+}
+
+// Status returns the worst status of all results.
+func (r Report) Status() checker.Status {
+	status := checker.StatusPass
+	for _, result := range r.Results {
+		status = checker.Worse(status, result.Status)
+	}
+	return status
+}
+
+// Count returns the number of results with the given status.
+func (r Report) Count(status checker.Status) int {
+	count := 0
+	for _, result := range r.Results {
+		if result.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+var categoryTitles = map[checker.Category]string{
+	checker.CategoryNetwork: fmt.Sprintf("Network %v", emoji.ElectricPlug),
+	checker.CategoryInfra:   fmt.Sprintf("InfraService %v", emoji.Joystick),
+	checker.CategoryNode:    fmt.Sprintf("Node %v", emoji.DesktopComputer),
+	checker.CategoryDisk:    fmt.Sprintf("Disk %v", emoji.ComputerDisk),
+}
+
+// CategoryTitle returns the heading printed above the checks of a category.
+func CategoryTitle(category checker.Category) string {
+	if title, ok := categoryTitles[category]; ok {
+		return title
+	}
+	return string(category)
+}
+
+var statusEmojis = map[checker.Status]emoji.Emoji{
+	checker.StatusPass: emoji.CheckMarkButton,
+	checker.StatusWarn: emoji.Warning,
+	checker.StatusFail: emoji.CrossMark,
+	checker.StatusSkip: emoji.NextTrackButton,
+}
+
+func StatusEmoji(status checker.Status) emoji.Emoji {
+	if e, ok := statusEmojis[status]; ok {
+		return e
+	}
+	return emoji.WhiteQuestionMark
+}
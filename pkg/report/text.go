@@ -0,0 +1,128 @@
+/*
+Copyright 2021 The IOMesh Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/iomesh/debugtool/pkg/checker"
+)
+
+// WriteText writes a human readable summary of the report.
+func WriteText(w io.Writer, r Report) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, "Summary")
+	if r.Cluster.KubernetesVersion != "" {
+		fmt.Fprintf(bw, "Kubernetes %s, %d nodes\n", r.Cluster.KubernetesVersion, len(r.Cluster.Nodes))
+	}
+	var lastCategory checker.Category
+	for _, result := range r.Results {
+		if result.Category != lastCategory {
+			fmt.Fprintln(bw, CategoryTitle(result.Category))
+			lastCategory = result.Category
+		}
+
+		line := fmt.Sprintf("  %v %s", StatusEmoji(result.Status), result.Name)
+		if result.Message != "" {
+			line += ": " + result.Message
+		}
+		if len(result.Measurements) > 0 {
+			line += " " + formatMeasurements(result.Measurements)
+		}
+		fmt.Fprintf(bw, "%s (%v)\n", line, result.Duration.Round(time.Millisecond))
+
+		// pairs are shown as a matrix of their main measurement per
+		// address family, only those that did not pass are listed one
+		// by one
+		matrices := Matrices(result)
+		for _, matrix := range matrices {
+			if matrix.Measurement == matrices[0].Measurement {
+				writeTextMatrix(bw, matrix)
+			}
+		}
+		for _, sr := range result.SubResults {
+			if len(matrices) > 0 && sr.Peer != "" && sr.Status == checker.StatusPass {
+				continue
+			}
+			line := fmt.Sprintf("      %v %s", StatusEmoji(sr.Status), sr.Name())
+			if sr.Message != "" {
+				line += ": " + sr.Message
+			}
+			if len(sr.Measurements) > 0 {
+				line += " " + formatMeasurements(sr.Measurements)
+			}
+			fmt.Fprintln(bw, line)
+		}
+		if result.Remediation != "" && result.Status != checker.StatusPass {
+			fmt.Fprintf(bw, "      Remediation: %s\n", result.Remediation)
+		}
+	}
+
+	summary := r.Summary()
+	fmt.Fprintf(bw, "\n%d checks: %d passed, %d warned, %d failed, %d skipped (%v)\n",
+		summary.Total, summary.Pass, summary.Warn, summary.Fail, summary.Skip,
+		r.Duration.Round(time.Millisecond))
+
+	return bw.Flush()
+}
+
+func formatMeasurements(measurements []checker.Measurement) string {
+	if len(measurements) == 0 {
+		return ""
+	}
+	parts := []string{}
+	for _, m := range measurements {
+		parts = append(parts, fmt.Sprintf("%s %.2f%s", m.Name, m.Value, m.Unit))
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+func writeTextMatrix(w io.Writer, matrix Matrix) {
+	title := matrix.Measurement
+	if title == "" {
+		title = "status"
+	}
+	if matrix.Unit != "" {
+		title = fmt.Sprintf("%s (%s)", title, matrix.Unit)
+	}
+	if matrix.Family != "" {
+		title = fmt.Sprintf("%s %s", matrix.Family, title)
+	}
+	fmt.Fprintf(w, "      %s, source \\ destination:\n", title)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "      \t%s\t\n", strings.Join(matrix.Nodes, "\t"))
+	for i, row := range matrix.Cells {
+		cells := []string{}
+		for _, cell := range row {
+			switch {
+			case cell.Measured:
+				cells = append(cells, fmt.Sprintf("%.2f", cell.Value))
+			case cell.Status != "":
+				cells = append(cells, string(cell.Status))
+			default:
+				cells = append(cells, "-")
+			}
+		}
+		fmt.Fprintf(tw, "      %s\t%s\t\n", matrix.Nodes[i], strings.Join(cells, "\t"))
+	}
+	tw.Flush()
+}